@@ -0,0 +1,174 @@
+package logd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMailWindow = time.Minute
+	maxDigestRecords  = 100 // 一封摘要邮件最多包含的日志条数, 超出的只计数
+	mailStopTimeout   = 30 * time.Second
+)
+
+// levelMailer 可选接口, 发送时带上摘要中的最高级别, 如Robot在Lfatal时@相关人员
+type levelMailer interface {
+	SendLevel(fromname string, lvl int, msg []byte) error
+}
+
+// alerter 将告警日志按窗口合并为一封摘要邮件, 并限制每小时发送数
+type alerter struct {
+	mu         sync.Mutex
+	l          *Logger
+	mails      Emailer
+	window     time.Duration
+	maxPerHour int
+	onError    func(error)
+	pending    [][]byte    // 待发送的日志
+	maxLvl     int         // 待发送日志的最高级别
+	omitted    int         // 超出maxDigestRecords未放入摘要的条数
+	first      time.Time   // 第一条待发送日志的时间
+	sent       []time.Time // 最近一小时的发送时间
+	quit       chan struct{}
+	done       chan struct{}
+	once       sync.Once
+	timeout    time.Duration // stop等待最后一封邮件的最长时间
+}
+
+func newAlerter(l *Logger, option LogOption) *alerter {
+	a := &alerter{
+		l:          l,
+		mails:      option.Mails,
+		window:     option.MailWindow,
+		maxPerHour: option.MailMaxPerHour,
+		onError:    option.MailError,
+		quit:       make(chan struct{}),
+		done:       make(chan struct{}),
+		timeout:    mailStopTimeout,
+	}
+	if a.window <= 0 {
+		a.window = defaultMailWindow
+	}
+	if a.onError == nil {
+		a.onError = func(err error) {
+			fmt.Fprintf(os.Stderr, "logd: send mail: %v\n", err)
+		}
+	}
+	go a.run()
+	return a
+}
+
+// add 加入待发送的告警
+func (a *alerter) add(lvl int, data []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if lvl > a.maxLvl {
+		a.maxLvl = lvl
+	}
+	if len(a.pending) == 0 && a.omitted == 0 {
+		a.first = time.Now()
+	}
+	if len(a.pending) < maxDigestRecords {
+		a.pending = append(a.pending, data)
+	} else {
+		a.omitted++
+	}
+}
+
+func (a *alerter) run() {
+	defer close(a.done)
+	ticker := time.NewTicker(a.window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.flush(false)
+		case <-a.quit:
+			a.flush(true)
+			return
+		}
+	}
+}
+
+// flush 发送摘要邮件, 超出每小时限制时保留到下个窗口, last为true时报告未发送的告警
+func (a *alerter) flush(last bool) {
+	a.mu.Lock()
+	if len(a.pending) == 0 && a.omitted == 0 {
+		a.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	if !a.allow(now) {
+		n := len(a.pending) + a.omitted
+		if last {
+			a.pending, a.omitted, a.maxLvl = nil, 0, 0
+		}
+		a.mu.Unlock()
+		if last {
+			a.onError(fmt.Errorf("%d alerts not sent: limit of %d mails per hour reached", n, a.maxPerHour))
+		}
+		return
+	}
+	records, omitted, first, lvl := a.pending, a.omitted, a.first, a.maxLvl
+	a.pending, a.omitted, a.maxLvl = nil, 0, 0
+	a.sent = append(a.sent, now)
+	a.mu.Unlock()
+
+	a.l.mu.Lock()
+	obj := a.l.obj
+	a.l.mu.Unlock()
+	msg := digest(obj, records, omitted, first, now)
+	var err error
+	if lm, ok := a.mails.(levelMailer); ok {
+		err = lm.SendLevel(obj, lvl, msg)
+	} else {
+		err = a.mails.SendMail(obj, msg)
+	}
+	if err != nil {
+		a.onError(err)
+	}
+}
+
+// allow 最近一小时内的发送数是否未超限, 调用时需持有a.mu
+func (a *alerter) allow(now time.Time) bool {
+	if a.maxPerHour <= 0 {
+		return true
+	}
+	i := 0
+	for i < len(a.sent) && now.Sub(a.sent[i]) >= time.Hour {
+		i++
+	}
+	a.sent = a.sent[i:]
+	return len(a.sent) < a.maxPerHour
+}
+
+// stop 发送剩余的告警并停止, 最多等待a.timeout, 使Fatal不会因邮件服务器无响应而挂起
+func (a *alerter) stop() {
+	a.once.Do(func() { close(a.quit) })
+	timer := time.NewTimer(a.timeout)
+	defer timer.Stop()
+	select {
+	case <-a.done:
+	case <-timer.C:
+		a.onError(fmt.Errorf("last alert mail not sent within %s", a.timeout))
+	}
+}
+
+func digest(obj string, records [][]byte, omitted int, first, last time.Time) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d alerts from %s between %s and %s\n\n", len(records)+omitted, obj,
+		first.Format("2006/01/02 15:04:05"), last.Format("2006/01/02 15:04:05"))
+	for _, r := range records {
+		buf.Write(r)
+		if len(r) > 0 && r[len(r)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+	}
+	if omitted > 0 {
+		fmt.Fprintf(&buf, "... and %d more\n", omitted)
+	}
+	return buf.Bytes()
+}
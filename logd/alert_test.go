@@ -0,0 +1,100 @@
+package logd
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeMailer struct {
+	mu   sync.Mutex
+	msgs []string
+	err  error
+}
+
+func (m *fakeMailer) SendMail(fromname string, msg []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.msgs = append(m.msgs, fromname+": "+string(msg))
+	return m.err
+}
+
+func TestAlertDigest(t *testing.T) {
+	m := &fakeMailer{}
+	var errs []error
+	l := New(LogOption{
+		Out:            ioutil.Discard,
+		Flag:           Lall,
+		Mails:          m,
+		MailWindow:     time.Hour,
+		MailMaxPerHour: 1,
+		MailError:      func(err error) { errs = append(errs, err) },
+	})
+	l.SetObj("api")
+	for i := 0; i < 150; i++ {
+		l.Warn("disk full")
+		l.Info("not an alert")
+	}
+	l.alert.flush(false)
+	l.Error("after limit")
+	l.Close()
+
+	if len(m.msgs) != 1 {
+		t.Fatalf("sent %d mails, want 1", len(m.msgs))
+	}
+	msg := m.msgs[0]
+	if !strings.HasPrefix(msg, "api: 150 alerts from api") ||
+		strings.Count(msg, "disk full") != maxDigestRecords ||
+		!strings.Contains(msg, "... and 50 more") ||
+		strings.Contains(msg, "not an alert") {
+		t.Errorf("unexpected digest:\n%s", msg)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "1 alerts not sent") {
+		t.Errorf("errors = %v", errs)
+	}
+}
+
+func TestAlertError(t *testing.T) {
+	m := &fakeMailer{err: errors.New("smtp down")}
+	var errs []error
+	l := New(LogOption{Out: ioutil.Discard, Flag: Lall, Mails: m, MailError: func(err error) { errs = append(errs, err) }})
+	l.Error("boom")
+	l.Close()
+	if len(errs) != 1 || errs[0] != m.err {
+		t.Errorf("errors = %v", errs)
+	}
+}
+
+// stallMailer 发送阻塞到release关闭
+type stallMailer struct{ release chan struct{} }
+
+func (m stallMailer) SendMail(fromname string, msg []byte) error {
+	<-m.release
+	return nil
+}
+
+func TestAlertStopTimeout(t *testing.T) {
+	m := stallMailer{release: make(chan struct{})}
+	defer close(m.release)
+	errc := make(chan error, 1)
+	l := New(LogOption{
+		Out:       ioutil.Discard,
+		Flag:      Lall,
+		Mails:     m,
+		MailError: func(err error) { errc <- err },
+	})
+	l.alert.timeout = 50 * time.Millisecond
+	l.Error("down")
+
+	start := time.Now()
+	l.Close()
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("Close blocked for %s", d)
+	}
+	if err := <-errc; !strings.Contains(err.Error(), "not sent within") {
+		t.Fatalf("error = %v", err)
+	}
+}
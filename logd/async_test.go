@@ -0,0 +1,152 @@
+package logd
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFlushAndClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	l := New(LogOption{Out: &buf, LogDir: dir, ChannelLen: 16, Flag: Lall | LAsync})
+	l.SetObj("app")
+	for i := 0; i < 100; i++ {
+		l.Infof("line %d\n", i)
+	}
+	if err := l.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 100 {
+		t.Errorf("flushed %d lines, want 100", n)
+	}
+
+	l.Info("last\n")
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "app_*.log"))
+	if len(files) != 1 {
+		t.Fatalf("log files %v", files)
+	}
+	data, _ := ioutil.ReadFile(files[0])
+	if n := strings.Count(string(data), "\n"); n != 101 {
+		t.Errorf("file has %d lines, want 101", n)
+	}
+
+	// 关闭后同步写到Out
+	l.Info("after close\n")
+	if !strings.HasSuffix(buf.String(), "after close\n") {
+		t.Errorf("output after close lost: %q", buf.String())
+	}
+	if err := l.Close(); err != nil {
+		t.Errorf("second close: %v", err)
+	}
+}
+
+// blockWriter 第一次Write时通知started, 然后阻塞到release关闭
+type blockWriter struct {
+	started chan struct{}
+	release chan struct{}
+	buf     bytes.Buffer
+}
+
+func (w *blockWriter) Write(p []byte) (int, error) {
+	select {
+	case <-w.started:
+	default:
+		close(w.started)
+		<-w.release
+	}
+	return w.buf.Write(p)
+}
+
+func TestOverflow(t *testing.T) {
+	tests := []struct {
+		overflow int
+		out      string
+		dropped  map[int]uint64
+	}{
+		{OverflowDropNewest, "a b ", map[int]uint64{Lwarn: 1, Lerror: 1}},
+		{OverflowDropOldest, "a d ", map[int]uint64{Linfo: 1, Lwarn: 1}},
+		{OverflowTimeout, "a b ", map[int]uint64{Lwarn: 1, Lerror: 1}},
+	}
+	for _, tt := range tests {
+		w := &blockWriter{started: make(chan struct{}), release: make(chan struct{})}
+		l := New(LogOption{
+			Out:          w,
+			ChannelLen:   1,
+			Flag:         Lall | LAsync,
+			Formatter:    upperFormatter{},
+			Overflow:     tt.overflow,
+			BlockTimeout: time.Millisecond,
+		})
+		l.Info("a ")
+		<-w.started
+		l.Info("b ")
+		l.Warn("c ")
+		l.Error("d ")
+		close(w.release)
+		l.Close()
+
+		var got string
+		for _, line := range strings.SplitAfter(w.buf.String(), " ") {
+			if i := strings.LastIndex(line, "|"); i >= 0 {
+				got += strings.ToLower(line[i+1:])
+			}
+		}
+		if got != tt.out {
+			t.Errorf("overflow %d: output %q, want %q", tt.overflow, got, tt.out)
+		}
+		for lvl := range levelMaps {
+			if n := l.Dropped()[lvl]; n != tt.dropped[lvl] {
+				t.Errorf("overflow %d: dropped[%s] = %d, want %d", tt.overflow, levelMaps[lvl], n, tt.dropped[lvl])
+			}
+		}
+	}
+}
+
+// 等待中的Flush不影响DropOldest: 多个并发写入都不阻塞
+func TestOverflow_DropOldestFlush(t *testing.T) {
+	w := &blockWriter{started: make(chan struct{}), release: make(chan struct{})}
+	l := New(LogOption{Out: w, ChannelLen: 1, Flag: Lall | LAsync, Overflow: OverflowDropOldest})
+	l.Info("a\n")
+	<-w.started
+	flushed := make(chan error, 1)
+	go func() {
+		flushed <- l.Flush(context.Background())
+	}()
+
+	done := make(chan struct{})
+	for p := 0; p < 8; p++ {
+		go func() {
+			for i := 0; i < 1000; i++ {
+				l.Info("b\n")
+			}
+			done <- struct{}{}
+		}()
+	}
+	timeout := time.After(5 * time.Second)
+	for p := 0; p < 8; p++ {
+		select {
+		case <-done:
+		case <-timeout:
+			t.Fatal("DropOldest blocked a producer")
+		}
+	}
+	close(w.release)
+	if err := <-flushed; err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+}
@@ -0,0 +1,98 @@
+package logd
+
+import (
+	"io"
+	"os"
+	"strconv"
+)
+
+// 颜色模式
+const (
+	ColorAuto   = iota // 输出为终端且未设置NO_COLOR时带颜色
+	ColorAlways        // 总是带颜色
+	ColorNever         // 从不带颜色
+)
+
+// ANSI前景色
+const (
+	Gray = uint8(iota + 90)
+	Red
+	Green
+	Yellow
+	Blue
+	Magenta
+)
+
+// ColorTheme 日志级别到ANSI颜色的映射, 未配置的级别不带颜色
+type ColorTheme map[int]uint8
+
+var DefaultColorTheme = ColorTheme{
+	Ldebug: Green,
+	Linfo:  Blue,
+	Lwarn:  Magenta,
+	Lerror: Yellow,
+	Lfatal: Red,
+}
+
+// applyColor 按颜色模式和当前输出设置文本格式的颜色, 调用时需持有l.mu
+func (l *Logger) applyColor() {
+	f, ok := l.formatter.(*TextFormatter)
+	if !ok {
+		return
+	}
+	// 复制一份, 避免与正在进行的Format竞争
+	tf := *f
+	switch l.color {
+	case ColorAlways:
+		tf.Color = true
+	case ColorNever:
+		tf.Color = false
+	default:
+		// 同时写日志文件时不带颜色
+		tf.Color = l.dir == "" && isTerminal(l.out) && !noColor()
+	}
+	if l.theme != nil {
+		tf.Theme = l.theme
+	}
+	l.formatter = &tf
+}
+
+// noColor 设置了NO_COLOR环境变量, 见 https://no-color.org
+func noColor() bool {
+	return os.Getenv("NO_COLOR") != ""
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// appendLevel 追加 [LEVEL], color为true时按theme加上颜色
+func appendLevel(buf *[]byte, lvl int, color bool, theme ColorTheme) {
+	if theme == nil {
+		theme = DefaultColorTheme
+	}
+	c, ok := theme[lvl]
+	if color && ok {
+		*buf = append(*buf, "\033["...)
+		*buf = strconv.AppendUint(*buf, uint64(c), 10)
+		*buf = append(*buf, 'm')
+	}
+	*buf = append(*buf, '[')
+	name := levelMaps[lvl]
+	for i := len(name); i < 5; i++ {
+		*buf = append(*buf, ' ')
+	}
+	*buf = append(*buf, name...)
+	*buf = append(*buf, ']')
+	if color && ok {
+		*buf = append(*buf, "\033[0m"...)
+	}
+}
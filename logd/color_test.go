@@ -0,0 +1,55 @@
+package logd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestColor(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(LogOption{Out: &buf, Flag: Lall})
+	l.Info("plain")
+	if got := buf.String(); got != "[ INFO] plain" {
+		t.Errorf("auto colour on non-terminal: %q", got)
+	}
+
+	buf.Reset()
+	l.SetColor(ColorAlways)
+	l.SetColorTheme(ColorTheme{Linfo: Gray})
+	l.Info("grey")
+	l.Warn("none")
+	if got := buf.String(); got != "\033[90m[ INFO]\033[0m grey[ WARN] none" {
+		t.Errorf("forced colour with theme: %q", got)
+	}
+
+	buf.Reset()
+	l.SetColor(ColorNever)
+	l.Info("off")
+	if got := buf.String(); strings.Contains(got, "\033[") {
+		t.Errorf("ColorNever still coloured: %q", got)
+	}
+}
+
+// SetOutput等修改格式和输出的方法与同步、异步输出并发, 用 -race 检查
+func TestColorSetters_Concurrent(t *testing.T) {
+	for _, flag := range []int{Lall, Lall | LAsync} {
+		l := New(LogOption{Out: ioutil.Discard, Flag: flag})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 200; i++ {
+				l.Info("x\n")
+			}
+		}()
+		for i := 0; i < 50; i++ {
+			l.SetOutput(ioutil.Discard)
+			l.SetColor(ColorAlways)
+			l.SetColorTheme(DefaultColorTheme)
+			l.SetLogDir("")
+		}
+		<-done
+		l.Close()
+	}
+}
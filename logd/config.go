@@ -0,0 +1,453 @@
+package logd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config 声明式配置, 可从json文件和LOGD_*环境变量读取, 见LoadConfig
+type Config struct {
+	Obj          string            `json:"obj"`            // 日志对象, 为空使用工作目录名
+	Level        string            `json:"level"`          // debug, info, warn, error, fatal, 默认debug
+	Format       string            `json:"format"`         // text(默认), json, logfmt
+	Output       string            `json:"output"`         // stdout(默认), stderr, none 或文件路径
+	Dir          string            `json:"dir"`            // 按天输出的日志目录, 需要async
+	Async        bool              `json:"async"`          // 异步输出
+	ChannelLen   int               `json:"channel_len"`    // 异步channel长度, 默认1000
+	Overflow     string            `json:"overflow"`       // block(默认), drop_newest, drop_oldest, timeout
+	BlockTimeout Duration          `json:"block_timeout"`  // overflow为timeout时的等待时间
+	Color        string            `json:"color"`          // auto(默认), always, never
+	Caller       string            `json:"caller"`         // short(默认), long, none
+	UTC          bool              `json:"utc"`            // 时间用UTC输出
+	Daily        bool              `json:"daily"`          // 压缩历史日志, 默认保留30天
+	MaxSize      Size              `json:"max_size"`       // 单个日志文件大小上限, 如 "100MB"
+	MaxAge       Duration          `json:"max_age"`        // 历史日志保留时间, 如 "720h"
+	MaxBackups   int               `json:"max_backups"`    // 最多保留的历史文件数
+	MaxTotalSize Size              `json:"max_total_size"` // 所有日志文件大小上限
+	Modules      map[string]string `json:"modules"`        // 模块级别, 如 {"db": "debug"}
+	Stack        string            `json:"stack"`          // 该级别及以上附带调用栈, 如 error, 为空不附带
+	StackDepth   int               `json:"stack_depth"`    // 调用栈最多帧数, 默认32
+	StackModule  string            `json:"stack_module"`   // 调用栈只保留该模块的帧
+	Mail         *MailConfig       `json:"mail"`
+	Ding         *DingConfig       `json:"ding"`
+}
+
+// MailConfig 告警邮件, 见Smtp
+type MailConfig struct {
+	Host       string   `json:"host"`
+	Port       string   `json:"port"`
+	From       string   `json:"from"`
+	User       string   `json:"user"`
+	Key        string   `json:"key"`
+	To         []string `json:"to"`
+	Subject    string   `json:"subject"`
+	TLS        string   `json:"tls"` // implicit(默认), starttls, none
+	Window     Duration `json:"window"`
+	MaxPerHour int      `json:"max_per_hour"`
+	Timeout    Duration `json:"timeout"` // 连接和发送超时, 默认30秒
+}
+
+// DingConfig 钉钉机器人告警, 见Robot, 与Mail同时配置时使用Ding
+type DingConfig struct {
+	Token     string   `json:"token"`
+	Secret    string   `json:"secret"`
+	AtMobiles []string `json:"at_mobiles"`
+	AtAll     bool     `json:"at_all"`
+}
+
+// Duration json中为 "30s", "720h" 等字符串或纳秒数
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n int64
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("invalid duration %s", data)
+		}
+		*d = Duration(n)
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Size json中为字节数或带单位的字符串: 512KB, 100MB, 1GB
+type Size int64
+
+func (s *Size) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		var n int64
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("invalid size %s", data)
+		}
+		*s = Size(n)
+		return nil
+	}
+	v, err := ParseSize(str)
+	if err != nil {
+		return err
+	}
+	*s = Size(v)
+	return nil
+}
+
+// ParseSize 解析带单位(K, KB, M, MB, G, GB, 1024进制)的字节数
+func ParseSize(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	mult := int64(1)
+	for _, u := range []struct {
+		suffix string
+		mult   int64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"B", 1}} {
+		if strings.HasSuffix(str, u.suffix) {
+			str, mult = strings.TrimSpace(strings.TrimSuffix(str, u.suffix)), u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
+
+// ConfigError 配置校验错误, 包含所有无效的配置项
+type ConfigError []string
+
+func (e ConfigError) Error() string {
+	return "logd: invalid config: " + strings.Join(e, "; ")
+}
+
+// LoadConfig 从json文件(path为空时跳过)读取配置, 再用LOGD_*环境变量覆盖, 创建logger.
+// 环境变量: LOGD_OBJ, LOGD_LEVEL, LOGD_FORMAT, LOGD_OUTPUT, LOGD_DIR, LOGD_ASYNC,
+// LOGD_CHANNEL_LEN, LOGD_OVERFLOW, LOGD_BLOCK_TIMEOUT, LOGD_COLOR, LOGD_CALLER, LOGD_UTC,
+// LOGD_DAILY, LOGD_MAX_SIZE, LOGD_MAX_AGE, LOGD_MAX_BACKUPS, LOGD_MAX_TOTAL_SIZE,
+// LOGD_MODULES(db=debug,http=error), LOGD_STACK, LOGD_STACK_DEPTH, LOGD_STACK_MODULE, LOGD_MAIL_HOST, LOGD_MAIL_PORT, LOGD_MAIL_FROM,
+// LOGD_MAIL_USER, LOGD_MAIL_KEY, LOGD_MAIL_TO(逗号分隔), LOGD_MAIL_SUBJECT, LOGD_MAIL_TLS,
+// LOGD_MAIL_WINDOW, LOGD_MAIL_MAX_PER_HOUR, LOGD_MAIL_TIMEOUT,
+// LOGD_DING_TOKEN, LOGD_DING_SECRET, LOGD_DING_AT_MOBILES(逗号分隔), LOGD_DING_AT_ALL
+func LoadConfig(path string) (*Logger, error) {
+	var c Config
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, fmt.Errorf("logd: parse %s: %v", path, err)
+		}
+	}
+	if err := c.LoadEnv(); err != nil {
+		return nil, err
+	}
+	return c.Build()
+}
+
+// LoadEnv 用LOGD_*环境变量覆盖配置
+func (c *Config) LoadEnv() error {
+	var errs ConfigError
+	str := func(name string, v *string) {
+		if s, ok := os.LookupEnv(name); ok {
+			*v = s
+		}
+	}
+	boolean := func(name string, v *bool) {
+		if s, ok := os.LookupEnv(name); ok {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: invalid bool %q", name, s))
+				return
+			}
+			*v = b
+		}
+	}
+	integer := func(name string, v *int) {
+		if s, ok := os.LookupEnv(name); ok {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: invalid integer %q", name, s))
+				return
+			}
+			*v = n
+		}
+	}
+	duration := func(name string, v *Duration) {
+		if s, ok := os.LookupEnv(name); ok {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: invalid duration %q", name, s))
+				return
+			}
+			*v = Duration(d)
+		}
+	}
+	size := func(name string, v *Size) {
+		if s, ok := os.LookupEnv(name); ok {
+			n, err := ParseSize(s)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+				return
+			}
+			*v = Size(n)
+		}
+	}
+
+	str("LOGD_OBJ", &c.Obj)
+	str("LOGD_LEVEL", &c.Level)
+	str("LOGD_FORMAT", &c.Format)
+	str("LOGD_OUTPUT", &c.Output)
+	str("LOGD_DIR", &c.Dir)
+	boolean("LOGD_ASYNC", &c.Async)
+	integer("LOGD_CHANNEL_LEN", &c.ChannelLen)
+	str("LOGD_OVERFLOW", &c.Overflow)
+	duration("LOGD_BLOCK_TIMEOUT", &c.BlockTimeout)
+	str("LOGD_COLOR", &c.Color)
+	str("LOGD_CALLER", &c.Caller)
+	boolean("LOGD_UTC", &c.UTC)
+	boolean("LOGD_DAILY", &c.Daily)
+	size("LOGD_MAX_SIZE", &c.MaxSize)
+	duration("LOGD_MAX_AGE", &c.MaxAge)
+	integer("LOGD_MAX_BACKUPS", &c.MaxBackups)
+	size("LOGD_MAX_TOTAL_SIZE", &c.MaxTotalSize)
+	str("LOGD_STACK", &c.Stack)
+	integer("LOGD_STACK_DEPTH", &c.StackDepth)
+	str("LOGD_STACK_MODULE", &c.StackModule)
+	if s, ok := os.LookupEnv("LOGD_MODULES"); ok {
+		c.Modules = make(map[string]string)
+		for _, kv := range strings.Split(s, ",") {
+			if kv = strings.TrimSpace(kv); kv == "" {
+				continue
+			}
+			i := strings.IndexByte(kv, '=')
+			if i <= 0 {
+				errs = append(errs, fmt.Sprintf("LOGD_MODULES: invalid entry %q, want name=level", kv))
+				continue
+			}
+			c.Modules[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	if s := os.Getenv("LOGD_MAIL_HOST"); s != "" && c.Mail == nil {
+		c.Mail = &MailConfig{}
+	}
+	if c.Mail != nil {
+		str("LOGD_MAIL_HOST", &c.Mail.Host)
+		str("LOGD_MAIL_PORT", &c.Mail.Port)
+		str("LOGD_MAIL_FROM", &c.Mail.From)
+		str("LOGD_MAIL_USER", &c.Mail.User)
+		str("LOGD_MAIL_KEY", &c.Mail.Key)
+		str("LOGD_MAIL_SUBJECT", &c.Mail.Subject)
+		str("LOGD_MAIL_TLS", &c.Mail.TLS)
+		duration("LOGD_MAIL_WINDOW", &c.Mail.Window)
+		integer("LOGD_MAIL_MAX_PER_HOUR", &c.Mail.MaxPerHour)
+		duration("LOGD_MAIL_TIMEOUT", &c.Mail.Timeout)
+		if s, ok := os.LookupEnv("LOGD_MAIL_TO"); ok {
+			c.Mail.To = strings.Split(s, ",")
+		}
+	}
+	if s := os.Getenv("LOGD_DING_TOKEN"); s != "" && c.Ding == nil {
+		c.Ding = &DingConfig{}
+	}
+	if c.Ding != nil {
+		str("LOGD_DING_TOKEN", &c.Ding.Token)
+		str("LOGD_DING_SECRET", &c.Ding.Secret)
+		boolean("LOGD_DING_AT_ALL", &c.Ding.AtAll)
+		if s, ok := os.LookupEnv("LOGD_DING_AT_MOBILES"); ok {
+			c.Ding.AtMobiles = strings.Split(s, ",")
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Option 校验配置并转换为LogOption, 所有无效项以ConfigError返回
+func (c *Config) Option() (LogOption, error) {
+	var errs ConfigError
+	option := LogOption{
+		ChannelLen:   c.ChannelLen,
+		BlockTimeout: time.Duration(c.BlockTimeout),
+		LogDir:       c.Dir,
+		MaxSize:      int64(c.MaxSize),
+		MaxAge:       time.Duration(c.MaxAge),
+		MaxBackups:   c.MaxBackups,
+		MaxTotalSize: int64(c.MaxTotalSize),
+		Flag:         Ldate | Lmicroseconds,
+		StackDepth:   c.StackDepth,
+		StackModule:  c.StackModule,
+	}
+	if option.ChannelLen == 0 {
+		option.ChannelLen = 1000
+	}
+
+	level := Ldebug
+	if c.Level != "" {
+		lvl, err := ParseLevel(c.Level)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("level: unknown level %q", c.Level))
+		} else {
+			level = lvl
+		}
+	}
+	option.Flag |= Lall &^ (level - 1)
+	if c.Stack != "" {
+		lvl, err := ParseLevel(c.Stack)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("stack: unknown level %q", c.Stack))
+		} else {
+			option.StackLevels = Lall &^ (lvl - 1)
+		}
+	}
+
+	switch c.Format {
+	case "", FormatText, FormatJSON, FormatLogfmt:
+		option.Format = c.Format
+	default:
+		errs = append(errs, fmt.Sprintf("format: unknown format %q", c.Format))
+	}
+
+	switch strings.ToLower(c.Output) {
+	case "", "stdout":
+		option.Out = os.Stdout
+	case "stderr":
+		option.Out = os.Stderr
+	}
+	// 文件输出在校验通过后由Build打开
+
+	if c.Async {
+		option.Flag |= LAsync
+	} else if c.Dir != "" {
+		errs = append(errs, "dir: requires async")
+	}
+	switch c.Overflow {
+	case "", "block":
+		option.Overflow = OverflowBlock
+	case "drop_newest":
+		option.Overflow = OverflowDropNewest
+	case "drop_oldest":
+		option.Overflow = OverflowDropOldest
+	case "timeout":
+		option.Overflow = OverflowTimeout
+		if c.BlockTimeout <= 0 {
+			errs = append(errs, "block_timeout: required for overflow timeout")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("overflow: unknown policy %q", c.Overflow))
+	}
+
+	switch c.Color {
+	case "", "auto":
+		option.Color = ColorAuto
+	case "always":
+		option.Color = ColorAlways
+	case "never":
+		option.Color = ColorNever
+	default:
+		errs = append(errs, fmt.Sprintf("color: unknown mode %q", c.Color))
+	}
+
+	switch c.Caller {
+	case "", "short":
+		option.Flag |= Lshortfile
+	case "long":
+		option.Flag |= Llongfile
+	case "none":
+	default:
+		errs = append(errs, fmt.Sprintf("caller: unknown mode %q", c.Caller))
+	}
+	if c.UTC {
+		option.Flag |= LUTC
+	}
+	if c.Daily {
+		option.Flag |= Ldaily
+	}
+
+	if c.ChannelLen < 0 {
+		errs = append(errs, "channel_len: must not be negative")
+	}
+	if c.StackDepth < 0 {
+		errs = append(errs, "stack_depth: must not be negative")
+	}
+	if c.MaxSize < 0 || c.MaxTotalSize < 0 || c.MaxAge < 0 || c.MaxBackups < 0 {
+		errs = append(errs, "max_size, max_age, max_backups, max_total_size: must not be negative")
+	}
+	for name, s := range c.Modules {
+		if _, err := ParseLevel(s); err != nil && s != "" {
+			errs = append(errs, fmt.Sprintf("modules.%s: unknown level %q", name, s))
+		}
+	}
+
+	if c.Mail != nil {
+		m := c.Mail
+		smtp := &Smtp{Host: m.Host, Port: m.Port, From: m.From, User: m.User, Key: m.Key, To: m.To, Subject: m.Subject,
+			Timeout: time.Duration(m.Timeout)}
+		switch strings.ToLower(m.TLS) {
+		case "", "implicit":
+			smtp.TLS = TLSImplicit
+		case "starttls":
+			smtp.TLS = TLSStartTLS
+		case "none":
+			smtp.TLS = TLSNone
+		default:
+			errs = append(errs, fmt.Sprintf("mail.tls: unknown mode %q", m.TLS))
+		}
+		if m.Host == "" || m.Port == "" || m.From == "" || len(m.To) == 0 {
+			errs = append(errs, "mail: host, port, from and to are required")
+		}
+		option.Mails = smtp
+		option.MailWindow = time.Duration(m.Window)
+		option.MailMaxPerHour = m.MaxPerHour
+	}
+	if c.Ding != nil {
+		if c.Ding.Token == "" {
+			errs = append(errs, "ding: token is required")
+		}
+		robot := NewRobot(c.Ding.Token, c.Ding.Secret)
+		robot.AtMobiles = c.Ding.AtMobiles
+		robot.AtAll = c.Ding.AtAll
+		option.Mails = robot
+	}
+
+	if len(errs) > 0 {
+		return option, errs
+	}
+	return option, nil
+}
+
+// Build 校验配置并创建logger
+func (c *Config) Build() (*Logger, error) {
+	option, err := c.Option()
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(c.Output) {
+	case "", "stdout", "stderr", "none":
+	default:
+		f, err := OpenFile(c.Output)
+		if err != nil {
+			return nil, err
+		}
+		option.Out = f
+	}
+	l := New(option)
+	if c.Obj != "" {
+		l.SetObj(c.Obj)
+	}
+	for name, s := range c.Modules {
+		lvl, _ := ParseLevel(s)
+		l.SetModuleLevel(name, lvl)
+	}
+	return l, nil
+}
@@ -0,0 +1,115 @@
+package logd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "logd.json")
+	ioutil.WriteFile(path, []byte(`{
+		"obj": "api",
+		"level": "info",
+		"format": "json",
+		"output": "none",
+		"dir": "`+dir+`",
+		"async": true,
+		"max_size": "10MB",
+		"max_age": "168h",
+		"modules": {"db": "debug"},
+		"mail": {"host": "smtp.example.com", "port": "587", "from": "a@example.com", "to": ["b@example.com"], "tls": "starttls", "window": "30s"}
+	}`), 0666)
+	os.Setenv("LOGD_LEVEL", "warn")
+	os.Setenv("LOGD_MAX_BACKUPS", "7")
+	defer os.Unsetenv("LOGD_LEVEL")
+	defer os.Unsetenv("LOGD_MAX_BACKUPS")
+
+	l, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if l.obj != "api" || l.Level() != Lwarn || l.flag&(LAsync|Lshortfile) != LAsync|Lshortfile {
+		t.Errorf("obj %q level %s flag %b", l.obj, LevelName(l.Level()), l.flag)
+	}
+	if _, ok := l.formatter.(*JSONFormatter); !ok {
+		t.Errorf("formatter %T", l.formatter)
+	}
+	if l.maxSize != 10<<20 || l.maxAge != 168*time.Hour || l.maxBackups != 7 || l.dir != dir {
+		t.Errorf("rotation %d %v %d %q", l.maxSize, l.maxAge, l.maxBackups, l.dir)
+	}
+	if l.ModuleLevels()["db"] != Ldebug {
+		t.Errorf("modules %v", l.ModuleLevels())
+	}
+	if s, ok := l.alert.mails.(*Smtp); !ok || s.TLS != TLSStartTLS || l.alert.window != 30*time.Second {
+		t.Errorf("mail %#v", l.alert.mails)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	c := Config{
+		Level:    "loud",
+		Format:   "xml",
+		Dir:      "/tmp",
+		Overflow: "timeout",
+		Modules:  map[string]string{"db": "verbose"},
+		Mail:     &MailConfig{TLS: "ssl"},
+	}
+	_, err := c.Build()
+	cerr, ok := err.(ConfigError)
+	if !ok {
+		t.Fatalf("err = %v", err)
+	}
+	for _, want := range []string{"level", "format", "dir", "block_timeout", "modules.db", "mail.tls", "mail:"} {
+		found := false
+		for _, e := range cerr {
+			found = found || strings.HasPrefix(e, want)
+		}
+		if !found {
+			t.Errorf("missing %q error in %v", want, cerr)
+		}
+	}
+
+	os.Setenv("LOGD_ASYNC", "maybe")
+	defer os.Unsetenv("LOGD_ASYNC")
+	if err := new(Config).LoadEnv(); err == nil || !strings.Contains(err.Error(), "LOGD_ASYNC") {
+		t.Errorf("LoadEnv: %v", err)
+	}
+}
+
+func TestLoadEnv_Alert(t *testing.T) {
+	env := map[string]string{
+		"LOGD_MAIL_HOST":         "smtp.example.com",
+		"LOGD_MAIL_WINDOW":       "1m",
+		"LOGD_MAIL_MAX_PER_HOUR": "5",
+		"LOGD_MAIL_TIMEOUT":      "10s",
+		"LOGD_DING_TOKEN":        "token",
+		"LOGD_DING_AT_MOBILES":   "13800000000,13900000000",
+		"LOGD_DING_AT_ALL":       "true",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	var c Config
+	if err := c.LoadEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if m := c.Mail; m == nil || time.Duration(m.Window) != time.Minute || m.MaxPerHour != 5 || time.Duration(m.Timeout) != 10*time.Second {
+		t.Errorf("mail %+v", c.Mail)
+	}
+	if d := c.Ding; d == nil || !d.AtAll || len(d.AtMobiles) != 2 || d.AtMobiles[1] != "13900000000" {
+		t.Errorf("ding %+v", c.Ding)
+	}
+}
@@ -0,0 +1,101 @@
+package logd
+
+import (
+	"context"
+	"sync"
+)
+
+// ContextExtractor 从context中取出附加到日志上的字段
+type ContextExtractor func(ctx context.Context) []Field
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	userIDKey
+	traceIDKey
+	spanIDKey
+)
+
+type contextKey struct {
+	key  interface{}
+	name string
+}
+
+var (
+	ctxKeysMu sync.RWMutex
+	ctxKeys   = []contextKey{
+		{requestIDKey, "request_id"},
+		{userIDKey, "user_id"},
+		{traceIDKey, "trace_id"},
+		{spanIDKey, "span_id"},
+	}
+)
+
+// RegisterContextKey 注册context中的key, DefaultContextExtractor以name为字段名取出其值
+func RegisterContextKey(key interface{}, name string) {
+	ctxKeysMu.Lock()
+	defer ctxKeysMu.Unlock()
+	for i := range ctxKeys {
+		if ctxKeys[i].key == key {
+			ctxKeys[i].name = name
+			return
+		}
+	}
+	ctxKeys = append(ctxKeys, contextKey{key: key, name: name})
+}
+
+// DefaultContextExtractor 按注册顺序取出context中存在的key
+func DefaultContextExtractor(ctx context.Context) []Field {
+	ctxKeysMu.RLock()
+	defer ctxKeysMu.RUnlock()
+	var fields []Field
+	for _, k := range ctxKeys {
+		if v := ctx.Value(k.key); v != nil {
+			fields = append(fields, Field{Key: k.name, Value: v})
+		}
+	}
+	return fields
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+func WithUserID(ctx context.Context, id interface{}) context.Context {
+	return context.WithValue(ctx, userIDKey, id)
+}
+
+func WithTrace(ctx context.Context, traceID, spanID string) context.Context {
+	ctx = context.WithValue(ctx, traceIDKey, traceID)
+	return context.WithValue(ctx, spanIDKey, spanID)
+}
+
+// Ctx 返回附加了ctx中字段的子logger, 字段由LogOption.ContextExtractor取出,
+// 未设置时使用DefaultContextExtractor
+func (l *Logger) Ctx(ctx context.Context) *Logger {
+	if ctx == nil {
+		return l
+	}
+	l.mu.Lock()
+	extract := l.extractor
+	l.mu.Unlock()
+	if extract == nil {
+		extract = DefaultContextExtractor
+	}
+	fields := extract(ctx)
+	if len(fields) == 0 {
+		return l
+	}
+	return l.withFields(fields)
+}
+
+func (l *Logger) SetContextExtractor(extract ContextExtractor) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.extractor = extract
+}
+
+func Ctx(ctx context.Context) *Logger {
+	return Std.Ctx(ctx)
+}
@@ -0,0 +1,33 @@
+package logd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+type tenantKey struct{}
+
+func TestCtx(t *testing.T) {
+	RegisterContextKey(tenantKey{}, "tenant")
+
+	var buf bytes.Buffer
+	l := New(LogOption{Out: &buf, Flag: Lall})
+	ctx := WithRequestID(context.Background(), "req-1")
+	ctx = WithTrace(ctx, "t1", "s1")
+	ctx = context.WithValue(ctx, tenantKey{}, "acme")
+	l.With("a", 1).Ctx(ctx).Info("hello\n")
+	if got := buf.String(); !strings.HasSuffix(got, "hello a=1 request_id=req-1 trace_id=t1 span_id=s1 tenant=acme\n") {
+		t.Errorf("default extractor: %q", got)
+	}
+
+	buf.Reset()
+	l.SetContextExtractor(func(ctx context.Context) []Field {
+		return []Field{{Key: "rid", Value: ctx.Value(requestIDKey)}}
+	})
+	l.Ctx(ctx).Info("custom\n")
+	if got := buf.String(); !strings.HasSuffix(got, "custom rid=req-1\n") {
+		t.Errorf("custom extractor: %q", got)
+	}
+}
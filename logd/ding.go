@@ -0,0 +1,127 @@
+package logd
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const dingWebhook = "https://oapi.dingtalk.com/robot/send"
+
+// dingClient Client为空时使用, 避免webhook无响应时一直阻塞
+var dingClient = &http.Client{Timeout: 10 * time.Second}
+
+// Robot 钉钉群机器人告警, 实现Emailer, 以markdown消息发送到加签的webhook
+type Robot struct {
+	Token     string       // access_token
+	Secret    string       // 加签密钥: SEC...
+	Webhook   string       // 为空使用 https://oapi.dingtalk.com/robot/send
+	Title     string       // 消息标题, 为空使用 "告警[obj]"
+	AtMobiles []string     // Lfatal时@的手机号
+	AtAll     bool         // Lfatal时@所有人
+	Client    *http.Client // 为空使用10秒超时的默认client
+}
+
+type dingMessage struct {
+	MsgType  string `json:"msgtype"`
+	Markdown struct {
+		Title string `json:"title"`
+		Text  string `json:"text"`
+	} `json:"markdown"`
+	At *dingAt `json:"at,omitempty"`
+}
+
+type dingAt struct {
+	AtMobiles []string `json:"atMobiles,omitempty"`
+	IsAtAll   bool     `json:"isAtAll,omitempty"`
+}
+
+func NewRobot(token, secret string) *Robot {
+	return &Robot{Token: token, Secret: secret}
+}
+
+func (r *Robot) SendMail(fromname string, msg []byte) error {
+	return r.SendLevel(fromname, Lwarn, msg)
+}
+
+// SendLevel 发送markdown消息, lvl为Lfatal时@AtMobiles或所有人
+func (r *Robot) SendLevel(fromname string, lvl int, msg []byte) error {
+	title := r.Title
+	if title == "" {
+		title = "告警[" + fromname + "]"
+	}
+	var text strings.Builder
+	text.WriteString("#### " + title + "\n\n")
+	for _, line := range strings.Split(strings.TrimRight(string(msg), "\n"), "\n") {
+		// markdown中单个换行不生效
+		text.WriteString(line + "\n\n")
+	}
+
+	var body dingMessage
+	body.MsgType = "markdown"
+	if lvl >= Lfatal && (len(r.AtMobiles) > 0 || r.AtAll) {
+		// 被@的手机号需要出现在正文中
+		for _, m := range r.AtMobiles {
+			text.WriteString("@" + m + " ")
+		}
+		body.At = &dingAt{AtMobiles: r.AtMobiles, IsAtAll: r.AtAll}
+	}
+	body.Markdown.Title = title
+	body.Markdown.Text = text.String()
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	client := r.Client
+	if client == nil {
+		client = dingClient
+	}
+	resp, err := client.Post(r.url(time.Now()), "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("dingtalk: %s: %v", resp.Status, err)
+	}
+	if result.ErrCode != 0 {
+		return fmt.Errorf("dingtalk: %d %s", result.ErrCode, result.ErrMsg)
+	}
+	return nil
+}
+
+// url 带access_token和加签参数的webhook地址
+func (r *Robot) url(t time.Time) string {
+	webhook := r.Webhook
+	if webhook == "" {
+		webhook = dingWebhook
+	}
+	q := url.Values{}
+	q.Set("access_token", r.Token)
+	if r.Secret != "" {
+		timestamp := strconv.FormatInt(t.UnixNano()/1e6, 10)
+		q.Set("timestamp", timestamp)
+		q.Set("sign", dingSign(timestamp, r.Secret))
+	}
+	return webhook + "?" + q.Encode()
+}
+
+// dingSign base64(hmac_sha256(timestamp + "\n" + secret, secret))
+func dingSign(timestamp, secret string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp + "\n" + secret))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,56 @@
+package logd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRobot(t *testing.T) {
+	var got []dingMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		ts := q.Get("timestamp")
+		ms, _ := strconv.ParseInt(ts, 10, 64)
+		if q.Get("access_token") != "tok" || q.Get("sign") != dingSign(ts, "SECxx") ||
+			time.Since(time.Unix(0, ms*1e6)) > time.Minute {
+			fmt.Fprint(w, `{"errcode":310000,"errmsg":"sign not match"}`)
+			return
+		}
+		var m dingMessage
+		json.NewDecoder(req.Body).Decode(&m)
+		got = append(got, m)
+		fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer srv.Close()
+
+	r := NewRobot("tok", "SECxx")
+	r.Webhook = srv.URL
+	r.AtMobiles = []string{"13800000000"}
+	if err := r.SendMail("api", []byte("line1\nline2\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SendLevel("api", Lfatal, []byte("dead")); err != nil {
+		t.Fatal(err)
+	}
+	r.Secret = "wrong"
+	if err := r.SendMail("api", []byte("x")); err == nil || !strings.Contains(err.Error(), "310000") {
+		t.Errorf("expected sign error, got %v", err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("received %d messages", len(got))
+	}
+	if m := got[0]; m.MsgType != "markdown" || m.Markdown.Title != "告警[api]" ||
+		m.Markdown.Text != "#### 告警[api]\n\nline1\n\nline2\n\n" || m.At != nil {
+		t.Errorf("warn message: %+v", m)
+	}
+	if m := got[1]; m.At == nil || len(m.At.AtMobiles) != 1 || !strings.Contains(m.Markdown.Text, "@13800000000") {
+		t.Errorf("fatal message: %+v", m)
+	}
+}
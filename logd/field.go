@@ -0,0 +1,70 @@
+package logd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Field 附加到每条日志上的键值对
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Fields 用于WithFields, 输出时按key排序
+type Fields map[string]interface{}
+
+// With 返回一个附加了键值对的子logger, 参数按 key, value, key, value... 排列.
+// 子logger与父logger共享输出、日志目录和异步channel.
+func (l *Logger) With(kv ...interface{}) *Logger {
+	fields := make([]Field, 0, (len(kv)+1)/2)
+	for i := 0; i < len(kv); i += 2 {
+		if i+1 == len(kv) {
+			fields = append(fields, Field{Key: "!BADKEY", Value: kv[i]})
+			break
+		}
+		key, ok := kv[i].(string)
+		if !ok {
+			key = fmt.Sprint(kv[i])
+		}
+		fields = append(fields, Field{Key: key, Value: kv[i+1]})
+	}
+	return l.withFields(fields)
+}
+
+// WithFields 同With, 字段按key排序
+func (l *Logger) WithFields(fields Fields) *Logger {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	list := make([]Field, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, Field{Key: k, Value: fields[k]})
+	}
+	return l.withFields(list)
+}
+
+func (l *Logger) withFields(fields []Field) *Logger {
+	all := make([]Field, 0, len(l.fields)+len(fields))
+	all = append(all, l.fields...)
+	all = append(all, fields...)
+	return &Logger{core: l.core, fields: all, module: l.module}
+}
+
+// appendFields 以 key=value 形式追加字段, 值含空白或引号时加引号
+func appendFields(buf *[]byte, fields []Field) {
+	for _, f := range fields {
+		*buf = append(*buf, ' ')
+		*buf = append(*buf, f.Key...)
+		*buf = append(*buf, '=')
+		v := fmt.Sprint(f.Value)
+		if v == "" || strings.ContainsAny(v, " \t\r\n\"=") {
+			v = strconv.Quote(v)
+		}
+		*buf = append(*buf, v...)
+	}
+}
@@ -0,0 +1,113 @@
+package logd
+
+import (
+	"os"
+	"sync"
+)
+
+// File 可重新打开的日志文件, 外部logrotate移走文件后调用Reopen(或收到SIGHUP)
+// 在原路径创建新文件. 写入和重新打开互斥, 不会丢失或交错日志.
+type File struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	closed bool // 已调用Close, 不再重新打开
+}
+
+// OpenFile 以追加方式打开日志文件
+func OpenFile(path string) (*File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return &File{path: path, file: file}, nil
+}
+
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	return f.file.Write(p)
+}
+
+// Reopen 重新打开原路径, 先打开新文件再关闭旧文件, 打开失败时继续写旧文件.
+// Close之后返回os.ErrClosed.
+func (f *File) Reopen() error {
+	f.mu.Lock()
+	closed := f.closed
+	f.mu.Unlock()
+	if closed {
+		return os.ErrClosed
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	if f.closed {
+		// 打开期间被Close
+		f.mu.Unlock()
+		file.Close()
+		return os.ErrClosed
+	}
+	old := f.file
+	f.file = file
+	f.mu.Unlock()
+	if old == nil {
+		return nil
+	}
+	old.Sync()
+	return old.Close()
+}
+
+func (f *File) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Sync()
+}
+
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *File) Name() string {
+	return f.path
+}
+
+// Reopen 重新打开输出和Sink中实现了Reopen() error的文件, 如File, 返回第一个错误
+func (l *Logger) Reopen() error {
+	l.mu.Lock()
+	targets := make([]interface{}, 0, len(l.sinks)+1)
+	targets = append(targets, l.out)
+	for _, s := range l.sinks {
+		targets = append(targets, s)
+	}
+	l.mu.Unlock()
+
+	var first error
+	for _, t := range targets {
+		if r, ok := t.(interface{ Reopen() error }); ok {
+			if err := r.Reopen(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+func Reopen() error {
+	return Std.Reopen()
+}
@@ -0,0 +1,97 @@
+package logd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	l := New(LogOption{Out: f, Flag: Lerror | LAsync, ChannelLen: 16})
+	const workers, n = 4, 200
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				l.Error(fmt.Sprintf("w%d-%d\n", w, i))
+			}
+		}(w)
+	}
+	// 模拟logrotate: 写入过程中移走文件再Reopen
+	for i := 1; i <= 3; i++ {
+		if err := os.Rename(path, fmt.Sprintf("%s.%d", path, i)); err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Reopen(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	l.Close()
+
+	files, _ := filepath.Glob(path + "*")
+	if len(files) != 4 {
+		t.Fatalf("files = %v", files)
+	}
+	seen := make(map[string]bool)
+	for _, name := range files {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+			if line == "" {
+				continue
+			}
+			if !strings.HasPrefix(line, "[ERROR] ") || strings.Count(line, "[ERROR]") != 1 {
+				t.Fatalf("bad line %q", line)
+			}
+			seen[strings.TrimPrefix(line, "[ERROR] ")] = true
+		}
+	}
+	if len(seen) != workers*n {
+		t.Fatalf("got %d records, want %d", len(seen), workers*n)
+	}
+}
+
+func TestFile_ReopenAfterClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	os.Remove(path)
+	if err := f.Reopen(); err != os.ErrClosed {
+		t.Fatalf("Reopen after Close = %v", err)
+	}
+	if _, err := f.Write([]byte("x\n")); err != os.ErrClosed {
+		t.Fatalf("Write after Close = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("file recreated: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package logd
+
+import (
+	"time"
+)
+
+// 内置格式名称, 用于LogOption.Format
+const (
+	FormatText   = "text"   // 带颜色的文本, 默认
+	FormatJSON   = "json"   // 每行一个JSON对象
+	FormatLogfmt = "logfmt" // 每行一条logfmt: key=value ...
+)
+
+// Record 一条日志记录, 交给Formatter编码
+type Record struct {
+	Time    time.Time
+	Level   int     // Ldebug ... Lfatal
+	Obj     string  // 打印日志对象
+	File    string  // 调用者文件全路径
+	Line    int     // 调用者行号
+	Message string  // 日志内容, 可能以换行结尾
+	Fields  []Field // With/WithFields附加的字段
+	Name    string  // Named创建的模块名, 如db.mongo
+	Stack   Stack   // 调用栈, 级别在StackLevels中时才有
+}
+
+// Formatter 将一条日志记录编码为输出的字节
+type Formatter interface {
+	Format(r *Record) []byte
+}
+
+// formatterByName 根据名称返回内置Formatter, 未知名称时使用文本格式
+func formatterByName(name string, flag int) Formatter {
+	switch name {
+	case FormatJSON:
+		return &JSONFormatter{Flag: flag}
+	case FormatLogfmt:
+		return &LogfmtFormatter{Flag: flag}
+	default:
+		return &TextFormatter{Flag: flag}
+	}
+}
+
+// shortFile 去掉文件路径中的目录
+func shortFile(file string) string {
+	for i := len(file) - 1; i > 0; i-- {
+		if file[i] == '/' {
+			return file[i+1:]
+		}
+	}
+	return file
+}
+
+// TextFormatter 带颜色的文本格式, 由Flag中的Ldate, Ltime, Lmicroseconds,
+// Llongfile, Lshortfile, LUTC控制头部输出
+// log format: date, time(hour:minute:second:microsecond), level, module, shortfile:line, <content>
+// Color为true时级别按Theme加上ANSI颜色, Theme为空使用DefaultColorTheme
+type TextFormatter struct {
+	Flag  int
+	Color bool
+	Theme ColorTheme
+}
+
+func (f *TextFormatter) Format(r *Record) []byte {
+	var buf []byte
+	f.formatHeader(&buf, r)
+	buf = append(buf, r.Message...)
+	if len(r.Fields) > 0 {
+		// 字段放在换行符之前
+		newline := len(buf) > 0 && buf[len(buf)-1] == '\n'
+		if newline {
+			buf = buf[:len(buf)-1]
+		}
+		appendFields(&buf, r.Fields)
+		if newline {
+			buf = append(buf, '\n')
+		}
+	}
+	if len(r.Stack) > 0 {
+		// 调用栈在消息之后, 每帧两行
+		if len(buf) > 0 && buf[len(buf)-1] != '\n' {
+			buf = append(buf, '\n')
+		}
+		r.Stack.appendText(&buf)
+	}
+	return buf
+}
+
+func (f *TextFormatter) formatHeader(buf *[]byte, r *Record) {
+	t, file := r.Time, r.File
+	if f.Flag&LUTC != 0 {
+		t = t.UTC()
+	}
+	if f.Flag&(Ldate|Ltime|Lmicroseconds) != 0 {
+		if (f.Flag & Ldate) != 0 {
+			year, month, day := t.Date()
+			itoa(buf, year, 4)
+			*buf = append(*buf, '/')
+			itoa(buf, int(month), 2)
+			*buf = append(*buf, '/')
+			itoa(buf, day, 2)
+			*buf = append(*buf, ' ')
+		}
+		if f.Flag&(Ltime|Lmicroseconds) != 0 {
+			hour, min, sec := t.Clock()
+			itoa(buf, hour, 2)
+			*buf = append(*buf, ':')
+			itoa(buf, min, 2)
+			*buf = append(*buf, ':')
+			itoa(buf, sec, 2)
+			if f.Flag&Lmicroseconds != 0 {
+				*buf = append(*buf, '.')
+				itoa(buf, t.Nanosecond()/1e3, 6)
+			}
+			*buf = append(*buf, ' ')
+		}
+	}
+	appendLevel(buf, r.Level, f.Color, f.Theme)
+	*buf = append(*buf, ' ')
+	if r.Name != "" {
+		*buf = append(*buf, '[')
+		*buf = append(*buf, r.Name...)
+		*buf = append(*buf, "] "...)
+	}
+	if f.Flag&(Lshortfile|Llongfile) != 0 {
+		if f.Flag&Lshortfile != 0 {
+			file = shortFile(file)
+		}
+		*buf = append(*buf, file...)
+		*buf = append(*buf, ':')
+		itoa(buf, r.Line, -1)
+		*buf = append(*buf, ": "...)
+	}
+}
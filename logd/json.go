@@ -0,0 +1,181 @@
+package logd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// json输出中保留的key, 同名字段会加上 "fields." 前缀
+var jsonReservedKeys = map[string]bool{
+	"time":   true,
+	"level":  true,
+	"obj":    true,
+	"logger": true,
+	"caller": true,
+	"msg":    true,
+	"stack":  true,
+}
+
+// JSONFormatter 每条记录输出一行json:
+// {"time":..,"level":..,"obj":..,"logger":..,"caller":..,"msg":..,<fields>,"stack":[..]}
+// Flag中的LUTC控制时区, Lshortfile/Llongfile控制caller
+type JSONFormatter struct {
+	Flag int
+}
+
+func (f *JSONFormatter) Format(r *Record) []byte {
+	t := r.Time
+	if f.Flag&LUTC != 0 {
+		t = t.UTC()
+	}
+	buf := make([]byte, 0, 128+len(r.Message))
+	buf = append(buf, `{"time":"`...)
+	buf = t.AppendFormat(buf, "2006-01-02T15:04:05.000000Z07:00")
+	buf = append(buf, `","level":`...)
+	appendJSONString(&buf, levelMaps[r.Level])
+	buf = append(buf, `,"obj":`...)
+	appendJSONString(&buf, r.Obj)
+	if r.Name != "" {
+		buf = append(buf, `,"logger":`...)
+		appendJSONString(&buf, r.Name)
+	}
+	if f.Flag&(Lshortfile|Llongfile) != 0 {
+		file := r.File
+		if f.Flag&Lshortfile != 0 {
+			file = shortFile(file)
+		}
+		buf = append(buf, `,"caller":`...)
+		appendJSONString(&buf, file+":"+strconv.Itoa(r.Line))
+	}
+	buf = append(buf, `,"msg":`...)
+	appendJSONString(&buf, strings.TrimRight(r.Message, "\n"))
+	for _, field := range r.Fields {
+		buf = append(buf, ',')
+		if jsonReservedKeys[field.Key] {
+			appendJSONString(&buf, "fields."+field.Key)
+		} else {
+			appendJSONString(&buf, field.Key)
+		}
+		buf = append(buf, ':')
+		appendJSONValue(&buf, field.Value)
+	}
+	if len(r.Stack) > 0 {
+		buf = append(buf, `,"stack":`...)
+		appendJSONStack(&buf, r.Stack, f.Flag&Lshortfile != 0)
+	}
+	return append(buf, "}\n"...)
+}
+
+func appendJSONValue(buf *[]byte, v interface{}) {
+	switch val := v.(type) {
+	case nil:
+		*buf = append(*buf, "null"...)
+	case string:
+		appendJSONString(buf, val)
+	case bool:
+		*buf = strconv.AppendBool(*buf, val)
+	case int:
+		*buf = strconv.AppendInt(*buf, int64(val), 10)
+	case int8:
+		*buf = strconv.AppendInt(*buf, int64(val), 10)
+	case int16:
+		*buf = strconv.AppendInt(*buf, int64(val), 10)
+	case int32:
+		*buf = strconv.AppendInt(*buf, int64(val), 10)
+	case int64:
+		*buf = strconv.AppendInt(*buf, val, 10)
+	case uint:
+		*buf = strconv.AppendUint(*buf, uint64(val), 10)
+	case uint8:
+		*buf = strconv.AppendUint(*buf, uint64(val), 10)
+	case uint16:
+		*buf = strconv.AppendUint(*buf, uint64(val), 10)
+	case uint32:
+		*buf = strconv.AppendUint(*buf, uint64(val), 10)
+	case uint64:
+		*buf = strconv.AppendUint(*buf, val, 10)
+	case float32:
+		appendJSONFloat(buf, float64(val), 32)
+	case float64:
+		appendJSONFloat(buf, val, 64)
+	case time.Duration:
+		appendJSONString(buf, val.String())
+	case time.Time:
+		appendJSONString(buf, val.Format(time.RFC3339Nano))
+	case error, fmt.Stringer:
+		// fmt处理nil指针接收者和方法panic, 输出<nil>或%!v(PANIC=...)
+		appendJSONString(buf, fmt.Sprint(val))
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			appendJSONString(buf, fmt.Sprint(val))
+			return
+		}
+		*buf = append(*buf, data...)
+	}
+}
+
+// NaN和Inf在json中不合法, 以字符串输出
+func appendJSONFloat(buf *[]byte, f float64, bitSize int) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		appendJSONString(buf, strconv.FormatFloat(f, 'g', -1, bitSize))
+		return
+	}
+	*buf = strconv.AppendFloat(*buf, f, 'g', -1, bitSize)
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString 追加带引号的json字符串, 非法utf8替换为\ufffd
+func appendJSONString(buf *[]byte, s string) {
+	*buf = append(*buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' {
+				i++
+				continue
+			}
+			*buf = append(*buf, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				*buf = append(*buf, '\\', b)
+			case '\n':
+				*buf = append(*buf, '\\', 'n')
+			case '\r':
+				*buf = append(*buf, '\\', 'r')
+			case '\t':
+				*buf = append(*buf, '\\', 't')
+			default:
+				*buf = append(*buf, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			*buf = append(*buf, s[start:i]...)
+			*buf = append(*buf, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		// U+2028/U+2029 在部分js解析器中是换行
+		if c == '\u2028' || c == '\u2029' {
+			*buf = append(*buf, s[start:i]...)
+			*buf = append(*buf, '\\', 'u', '2', '0', '2', hexDigits[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	*buf = append(*buf, s[start:]...)
+	*buf = append(*buf, '"')
+}
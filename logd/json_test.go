@@ -0,0 +1,69 @@
+package logd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(LogOption{Out: &buf, Flag: Lall | Lshortfile, Format: FormatJSON})
+	l.SetObj("api")
+	l.With("user", "a\"b\n\x01", "n", 3, "msg", "dup").Warnf("bad \xff input\n")
+
+	line := buf.String()
+	if strings.Contains(line, "\033[") {
+		t.Errorf("colour codes in json output: %q", line)
+	}
+	if strings.Count(line, "\n") != 1 || !strings.HasSuffix(line, "}\n") {
+		t.Fatalf("expected one json line, got %q", line)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &m); err != nil {
+		t.Fatalf("invalid json %q: %v", line, err)
+	}
+	want := map[string]interface{}{
+		"level":      "WARN",
+		"obj":        "api",
+		"msg":        "bad \ufffd input",
+		"user":       "a\"b\n\x01",
+		"n":          float64(3),
+		"fields.msg": "dup",
+	}
+	for k, v := range want {
+		if m[k] != v {
+			t.Errorf("%s = %#v, want %#v", k, m[k], v)
+		}
+	}
+	if c, _ := m["caller"].(string); !strings.HasPrefix(c, "json_test.go:") {
+		t.Errorf("caller = %q", c)
+	}
+}
+
+type nilErr struct{ msg string }
+
+func (e *nilErr) Error() string { return e.msg }
+
+type nilStringer struct{ s string }
+
+func (s *nilStringer) String() string { return s.s }
+
+type panicStringer struct{}
+
+func (panicStringer) String() string { panic("bad") }
+
+func TestJSONFormat_NilReceiver(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(LogOption{Out: &buf, Flag: Lall, Format: FormatJSON})
+	l.With("err", (*nilErr)(nil), "s", (*nilStringer)(nil), "p", panicStringer{}).Error("nil values\n")
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatalf("invalid json %q: %v", buf.String(), err)
+	}
+	if m["err"] != "<nil>" || m["s"] != "<nil>" || m["p"] != "%!v(PANIC=String method: bad)" {
+		t.Fatalf("got %q", buf.String())
+	}
+}
@@ -0,0 +1,136 @@
+package logd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// ParseLevel 解析级别名称: debug, info, warn(warning), error, fatal, 不区分大小写
+func ParseLevel(name string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return Ldebug, nil
+	case "info":
+		return Linfo, nil
+	case "warn", "warning":
+		return Lwarn, nil
+	case "error":
+		return Lerror, nil
+	case "fatal":
+		return Lfatal, nil
+	}
+	return 0, fmt.Errorf("logd: unknown level %q", name)
+}
+
+// LevelName 返回级别的小写名称, 如 Lwarn 返回 "warn", 多个级别时取最低的
+func LevelName(lvl int) string {
+	return strings.ToLower(levelMaps[lowestLevel(lvl)])
+}
+
+// Level 返回根logger输出的最低级别, Named创建的logger返回生效的模块级别
+func (l *Logger) Level() int {
+	for m := l.module; m != nil; m = m.parent {
+		if min := int(atomic.LoadInt32(&m.level)); min != 0 {
+			return min
+		}
+	}
+	return lowestLevel(int(atomic.LoadInt32(&l.levels)))
+}
+
+// levelState LevelHandler的请求和响应, 模块级别为空表示继承上级
+type levelState struct {
+	Level   string            `json:"level,omitempty"`
+	Modules map[string]string `json:"modules,omitempty"`
+}
+
+// LevelHandler 查看和修改级别的http.Handler:
+//
+//	GET  返回 {"level":"warn","modules":{"db":"debug","http":""}}
+//	PUT/POST 请求体同上, 只修改给出的部分, 模块级别为空时恢复继承
+func (l *Logger) LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var req levelState
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeLevelError(w, http.StatusBadRequest, err)
+				return
+			}
+			if err := l.applyLevels(req); err != nil {
+				writeLevelError(w, http.StatusBadRequest, err)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			writeLevelError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(l.levelState())
+	})
+}
+
+func (l *Logger) levelState() levelState {
+	state := levelState{Level: LevelName(l.Level()), Modules: map[string]string{}}
+	for name, lvl := range l.ModuleLevels() {
+		if lvl == 0 {
+			state.Modules[name] = ""
+		} else {
+			state.Modules[name] = LevelName(lvl)
+		}
+	}
+	return state
+}
+
+// applyLevels 先校验全部级别再修改, 出错时不做任何修改
+func (l *Logger) applyLevels(req levelState) error {
+	var root int
+	if req.Level != "" {
+		lvl, err := ParseLevel(req.Level)
+		if err != nil {
+			return err
+		}
+		root = lvl
+	}
+	modules := make(map[string]int, len(req.Modules))
+	for name, s := range req.Modules {
+		if name == "" {
+			return fmt.Errorf("logd: empty module name")
+		}
+		if s == "" {
+			modules[name] = 0
+			continue
+		}
+		lvl, err := ParseLevel(s)
+		if err != nil {
+			return err
+		}
+		modules[name] = lvl
+	}
+	if root != 0 {
+		l.root().SetLevel(root)
+	}
+	for name, lvl := range modules {
+		l.SetModuleLevel(name, lvl)
+	}
+	return nil
+}
+
+// root 返回共享同一输出的根logger
+func (l *Logger) root() *Logger {
+	return &Logger{core: l.core}
+}
+
+func writeLevelError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+func LevelHandler() http.Handler {
+	return Std.LevelHandler()
+}
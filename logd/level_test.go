@@ -0,0 +1,69 @@
+package logd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLevelHandler(t *testing.T) {
+	l := New(LogOption{Out: ioutil.Discard, Flag: Lwarn | Lerror | Lfatal})
+	l.Named("db.mongo")
+	srv := httptest.NewServer(l.LevelHandler())
+	defer srv.Close()
+
+	do := func(method, body string) (int, levelState) {
+		req, _ := http.NewRequest(method, srv.URL, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var state levelState
+		json.NewDecoder(resp.Body).Decode(&state)
+		return resp.StatusCode, state
+	}
+
+	code, state := do("GET", "")
+	if code != 200 || state.Level != "warn" || len(state.Modules) != 2 || state.Modules["db"] != "" {
+		t.Errorf("GET: %d %+v", code, state)
+	}
+
+	code, state = do("PUT", `{"level":"error","modules":{"db":"debug","http":"info"}}`)
+	if code != 200 || state.Level != "error" || state.Modules["db"] != "debug" || state.Modules["http"] != "info" {
+		t.Errorf("PUT: %d %+v", code, state)
+	}
+	if !l.Named("db").Named("mongo").enabled(Ldebug) || l.enabled(Lwarn) {
+		t.Error("levels not applied")
+	}
+
+	code, _ = do("PUT", `{"level":"loud","modules":{"db":""}}`)
+	if code != http.StatusBadRequest || l.Named("db").Level() != Ldebug {
+		t.Errorf("invalid level: %d, db level %d", code, l.Named("db").Level())
+	}
+}
+
+// 运行时修改级别与输出并发, 用 -race 检查
+func TestLevelHandler_Concurrent(t *testing.T) {
+	l := New(LogOption{Out: ioutil.Discard, Flag: Lall})
+	h := l.LevelHandler()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			l.Debugf("n=%d", i)
+			l.Level()
+		}
+	}()
+	for _, lvl := range []string{"error", "debug", "warn"} {
+		req := httptest.NewRequest("PUT", "/", strings.NewReader(`{"level":"`+lvl+`"}`))
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	<-done
+	if l.Level() != Lwarn || l.enabled(Linfo) || !l.enabled(Lerror) {
+		t.Fatalf("level = %s", LevelName(l.Level()))
+	}
+}
@@ -1,15 +1,15 @@
 package logd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
-	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -42,171 +42,324 @@ var levelMaps = map[int]string{
 }
 
 type Logger struct {
-	mu    sync.Mutex
-	obj   string      // 打印日志对象
-	out   io.Writer   // 输出
-	in    chan []byte // channel
-	dir   string      // 输出目录
-	flag  int         // 标志
-	mails Emailer     // 告警邮件
+	*core
+	fields []Field // 附加字段, 由With/WithFields设置
+	module *module // Named创建的模块, 根logger为nil
+}
+
+// core 为同一个根logger派生出的所有logger共享
+type core struct {
+	dropped      [5]uint64 // 按级别丢弃的日志数, 原子操作, 放在首位保证64位对齐
+	levels       int32     // 根logger输出的级别位, 原子操作, SetLevel修改
+	mu           sync.Mutex
+	obj          string             // 打印日志对象
+	out          io.Writer          // 输出
+	in           chan entry         // channel
+	flushReq     chan chan struct{} // Flush请求, 单独的channel, 不会被overflow策略丢弃
+	done         chan struct{}      // receive退出后关闭
+	closeMu      sync.RWMutex       // 保护closed, 发送到in时持有读锁
+	closed       bool               // 已调用Close
+	closeErr     error              // 关闭日志文件的错误
+	overflow     int                // channel满时的策略
+	blockTimeout time.Duration      // OverflowTimeout的等待时间
+	dir          string             // 输出目录
+	maxSize      int64              // 单个日志文件最大字节数
+	maxAge       time.Duration      // 历史日志最长保留时间
+	maxBackups   int                // 最多保留的历史文件数
+	maxTotalSize int64              // 日志文件总字节数上限
+	rotateMu     sync.Mutex         // 串行执行rotate
+	rotating     sync.WaitGroup     // 正在执行的rotate, Close时等待
+	activeFile   string             // 正在写的分段文件名, 受mu保护
+	flag         int                // 标志, New之后不再修改, 级别以levels为准
+	formatter    Formatter          // 日志格式
+	color        int                // 颜色模式: ColorAuto, ColorAlways, ColorNever
+	theme        ColorTheme         // 级别颜色
+	sinks        []Sink             // 除out和dir外的输出, 写时复制
+	extractor    ContextExtractor   // Ctx使用的字段提取
+	modules      map[string]*module // 模块名到模块级别
+	sampler      *sampler           // 重复日志采样
+	alert        *alerter           // 告警邮件
+	stackLevels  int32              // 附带调用栈的级别, 原子操作
+	stackDepth   int                // 调用栈最多帧数
+	stackModule  string             // 只保留该模块的帧
+	panicLevel   int                // Recover输出panic的级别
+	repanic      bool               // Recover输出后重新panic
 }
 
 type LogOption struct {
-	Out        io.Writer // 输出writer
-	LogDir     string    // 日志输出目录, 为空不输出到文件
-	ChannelLen int       // channel
-	Flag       int       // 标志位
-	Mails      Emailer   // 告警邮件
+	Out              io.Writer        // 输出writer
+	LogDir           string           // 日志输出目录, 为空不输出到文件
+	MaxSize          int64            // 单个日志文件最大字节数, 超过后切分为 obj_2006-01-02.1.log ..., 0不限制
+	MaxAge           time.Duration    // 历史日志最长保留时间, 0不限制, Ldaily时默认30天
+	MaxBackups       int              // 最多保留的历史文件数, 0不限制
+	MaxTotalSize     int64            // 所有日志文件的总字节数上限, 0不限制
+	ChannelLen       int              // channel
+	Overflow         int              // channel满时的策略: OverflowBlock(默认), OverflowDropNewest, OverflowDropOldest, OverflowTimeout
+	BlockTimeout     time.Duration    // OverflowTimeout时最长等待时间, 超时丢弃
+	Flag             int              // 标志位
+	Format           string           // 输出格式: FormatText(默认), FormatJSON, FormatLogfmt
+	Formatter        Formatter        // 自定义格式, 不为空时忽略Format
+	Color            int              // 颜色模式, 默认ColorAuto: 仅输出到终端时带颜色
+	Theme            ColorTheme       // 级别颜色, 为空使用DefaultColorTheme
+	Sinks            []Sink           // 除Out和LogDir外的输出, 每个Sink有自己的级别和格式
+	Sampling         *Sampling        // 按调用位置采样, 为空不采样
+	ContextExtractor ContextExtractor // Logger.Ctx从context中取字段, 为空使用DefaultContextExtractor
+	Mails            Emailer          // 告警邮件, Lwarn及以上的日志按MailWindow合并发送
+	MailWindow       time.Duration    // 告警合并窗口, 默认1分钟
+	MailMaxPerHour   int              // 每小时最多发送的邮件数, 0不限制
+	MailError        func(error)      // 邮件发送失败时调用, 为空时输出到stderr
+	StackLevels      int              // 附带调用栈的级别, 如 Lerror|Lfatal, 0不附带
+	StackDepth       int              // 调用栈最多帧数, 默认32
+	StackModule      string           // 不为空时调用栈只保留该模块路径下和main包的帧, 如 github.com/yahao333/utils
+	PanicLevel       int              // Recover, Go, RecoverHandler输出panic的级别: Lerror(默认)或Lfatal
+	Repanic          bool             // Recover等输出并Flush后重新panic
 }
 
 func New(option LogOption) *Logger {
 	wd, _ := os.Getwd()
 	index := strings.LastIndex(wd, "/")
-	logger := &Logger{
-		obj:   wd[index+1:],
-		out:   option.Out,
-		in:    make(chan []byte, option.ChannelLen),
-		dir:   option.LogDir,
-		flag:  option.Flag,
-		mails: option.Mails,
-	}
-	if logger.flag|LAsync != 0 {
+	logger := &Logger{core: &core{
+		obj:          wd[index+1:],
+		out:          option.Out,
+		in:           make(chan entry, option.ChannelLen),
+		flushReq:     make(chan chan struct{}),
+		done:         make(chan struct{}),
+		overflow:     option.Overflow,
+		blockTimeout: option.BlockTimeout,
+		dir:          option.LogDir,
+		maxSize:      option.MaxSize,
+		maxAge:       option.MaxAge,
+		maxBackups:   option.MaxBackups,
+		maxTotalSize: option.MaxTotalSize,
+		levels:       int32(option.Flag & Lall),
+		flag:         option.Flag,
+		formatter:    option.Formatter,
+		color:        option.Color,
+		theme:        option.Theme,
+		extractor:    option.ContextExtractor,
+		stackLevels:  int32(option.StackLevels & Lall),
+		stackDepth:   option.StackDepth,
+		stackModule:  option.StackModule,
+		panicLevel:   option.PanicLevel,
+		repanic:      option.Repanic,
+	}}
+	if logger.formatter == nil {
+		logger.formatter = formatterByName(option.Format, option.Flag)
+	}
+	logger.applyColor()
+	for _, sink := range option.Sinks {
+		logger.AddSink(sink)
+	}
+	if option.Mails != nil {
+		logger.alert = newAlerter(logger, option)
+	}
+	if option.Sampling != nil {
+		logger.sampler = newSampler(logger, *option.Sampling)
+	}
+	if logger.flag&LAsync != 0 {
 		go logger.receive()
+	} else {
+		close(logger.done)
 	}
 	return logger
 }
 
+// entry 异步channel中的一项
+type entry struct {
+	lvl  int
+	data []byte
+	rec  *Record // 写入sinks
+}
+
 func (l *Logger) receive() {
-	today := time.Now()
-	var file *os.File
-	var err error
-	for data := range l.in {
-		if l.dir != "" && (file == nil || today.Day() != time.Now().Day()) {
-			l.mu.Lock()
-			today = time.Now()
-			file, err = os.OpenFile(fmt.Sprintf("%s/%s_%s.log", l.dir,
-				l.obj, today.Format("2006-01-02")),
-				os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
-			if err != nil {
-				panic(err)
+	var lf logFile
+	defer func() {
+		l.closeErr = lf.close()
+		l.mu.Lock()
+		syncWriter(l.out)
+		l.mu.Unlock()
+		close(l.done)
+	}()
+	for {
+		select {
+		case e, ok := <-l.in:
+			if !ok {
+				return
 			}
-			l.mu.Unlock()
-			if (l.flag & Ldaily) != 0 {
-				go l.rotate(today)
+			l.writeEntry(&lf, e)
+		case done := <-l.flushReq:
+			// 先写完Flush之前已进入channel的日志
+			for n := len(l.in); n > 0; n-- {
+				var e entry
+				var ok bool
+				select {
+				case e, ok = <-l.in:
+				default:
+				}
+				if !ok {
+					break
+				}
+				l.writeEntry(&lf, e)
 			}
-		}
-		if file != nil {
-			file.Write(data)
-		}
-		if l.out != nil {
-			l.out.Write(data)
+			lf.sync()
+			l.mu.Lock()
+			syncWriter(l.out)
+			syncSinks(l.sinks)
+			l.mu.Unlock()
+			close(done)
 		}
 	}
 }
 
-// 压缩,依赖命令行gzip
-func (l *Logger) rotate(t time.Time) {
-	filepath.Walk(l.dir, func(path string, f os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if int(t.Sub(f.ModTime()).Hours()) > 24 {
-			if strings.HasSuffix(f.Name(), ".log") {
-				cmd := exec.Command("gzip", path)
-				err = cmd.Run()
-				if err != nil {
-					return err
-				}
-			}
-		}
-		if int(t.Sub(f.ModTime()).Hours()) > 24*30 {
-			if err := os.Remove(path); err != nil {
-				return err
-			}
+func (l *Logger) writeEntry(lf *logFile, e entry) {
+	// SetOutput, SetLogDir可与输出并发
+	l.mu.Lock()
+	dir, out, sinks := l.dir, l.out, l.sinks
+	l.mu.Unlock()
+	if dir != "" {
+		if err := l.writeFile(lf, e.data); err != nil {
+			panic(err)
 		}
-		return nil
-	})
+	}
+	if out != nil {
+		out.Write(e.data)
+	}
+	writeSinks(sinks, e.rec)
 }
 
-// log format: date, time(hour:minute:second:microsecond), level, module, shortfile:line, <content>
+// Output 输出一条日志, calldepth为调用者相对Output的栈深度
 func (l *Logger) Output(lvl int, calldepth int, content string) error {
 	_, file, line, ok := runtime.Caller(calldepth)
 	if !ok {
 		return nil
 	}
+	return l.outputAt(lvl, calldepth+1, file, line, content)
+}
+
+// outputAt 以已知的调用位置输出, calldepth为调用栈开始处相对outputAt的深度
+func (l *Logger) outputAt(lvl int, calldepth int, file string, line int, content string) error {
+	if l.sampler != nil && !l.sampler.allow(lvl, file, line) {
+		return nil
+	}
 
-	var buf []byte
-	l.formatHeader(&buf, lvl, time.Now(), file, line)
-	buf = append(buf, content...)
-	if l.mails != nil && lvl >= Lwarn {
-		go l.mails.SendMail(l.obj, buf)
+	return l.write(&Record{
+		Time:    time.Now(),
+		Level:   lvl,
+		Obj:     l.getObj(),
+		File:    file,
+		Line:    line,
+		Message: content,
+		Fields:  l.fields,
+		Name:    l.Name(),
+		Stack:   l.captureStack(lvl, calldepth),
+	})
+}
+
+// getObj 在l.mu下读取obj, SetObj可与输出并发
+func (l *Logger) getObj() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.obj
+}
+
+// write 编码并输出一条记录
+func (l *Logger) write(r *Record) error {
+	lvl := r.Level
+	l.mu.Lock()
+	formatter := l.formatter
+	l.mu.Unlock()
+	buf := formatter.Format(r)
+	if l.alert != nil && lvl >= Lwarn {
+		l.alert.add(lvl, buf)
 	}
-	if l.flag&LAsync != 0 {
-		l.in <- buf
-	} else {
-		l.mu.Lock()
-		defer l.mu.Unlock()
+	if l.flag&LAsync != 0 && l.send(entry{lvl: lvl, data: buf, rec: r}) {
+		return nil
+	}
+	// 同步输出, 或异步logger已关闭
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
+	if l.out != nil {
 		l.out.Write(buf)
 	}
-	return nil
+	return writeSinks(l.sinks, r)
 }
 
-func (l *Logger) formatHeader(buf *[]byte, lvl int, t time.Time, file string, line int) {
-	if l.flag&LUTC != 0 {
-		t = t.UTC()
+// WaitFlush 等待异步channel中的日志写完
+func (l *Logger) WaitFlush() {
+	l.Flush(context.Background())
+}
+
+// Flush 等待调用之前的异步日志写入并fsync, ctx结束时返回ctx.Err()
+func (l *Logger) Flush(ctx context.Context) error {
+	if l.flag&LAsync == 0 {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		syncWriter(l.out)
+		syncSinks(l.sinks)
+		return nil
 	}
-	if l.flag&(Ldate|Ltime|Lmicroseconds) != 0 {
-		if (l.flag & Ldate) != 0 {
-			year, month, day := t.Date()
-			itoa(buf, year, 4)
-			*buf = append(*buf, '/')
-			itoa(buf, int(month), 2)
-			*buf = append(*buf, '/')
-			itoa(buf, day, 2)
-			*buf = append(*buf, ' ')
-		}
-		if l.flag&(Ltime|Lmicroseconds) != 0 {
-			hour, min, sec := t.Clock()
-			itoa(buf, hour, 2)
-			*buf = append(*buf, ':')
-			itoa(buf, min, 2)
-			*buf = append(*buf, ':')
-			itoa(buf, sec, 2)
-			if l.flag&Lmicroseconds != 0 {
-				*buf = append(*buf, '.')
-				itoa(buf, t.Nanosecond()/1e3, 6)
-			}
-			*buf = append(*buf, ' ')
+	done := make(chan struct{})
+	l.closeMu.RLock()
+	if l.closed {
+		// Close已经写完所有日志
+		l.closeMu.RUnlock()
+		<-l.done
+		return nil
+	}
+	select {
+	case l.flushReq <- done:
+		l.closeMu.RUnlock()
+	case <-ctx.Done():
+		l.closeMu.RUnlock()
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close 写完异步channel中的日志, fsync并关闭日志文件和实现了io.Closer的Sink, 等待receive退出.
+// 子logger共享同一个输出, 关闭任一个即关闭全部. 关闭后的日志同步写到Out.
+func (l *Logger) Close() error {
+	if l.sampler != nil {
+		l.sampler.stop()
+	}
+	l.closeMu.Lock()
+	first := !l.closed
+	if first {
+		l.closed = true
+		if l.flag&LAsync != 0 {
+			close(l.in)
 		}
 	}
-	*buf = append(*buf, getColorLevel(levelMaps[lvl])...)
-	*buf = append(*buf, ' ')
-	if l.flag&(Lshortfile|Llongfile) != 0 {
-		if l.flag&Lshortfile != 0 {
-			short := file
-			for i := len(file) - 1; i > 0; i-- {
-				if file[i] == '/' {
-					short = file[i+1:]
-					break
-				}
-			}
-			file = short
+	l.closeMu.Unlock()
+	<-l.done
+	// 等待压缩和清理结束, 避免Fatal退出后留下 .gz.tmp
+	l.rotating.Wait()
+	l.mu.Lock()
+	if l.flag&LAsync == 0 {
+		syncWriter(l.out)
+	}
+	if first {
+		if err := l.closeSinks(); l.closeErr == nil {
+			l.closeErr = err
 		}
-		*buf = append(*buf, file...)
-		*buf = append(*buf, ':')
-		itoa(buf, line, -1)
-		*buf = append(*buf, ": "...)
 	}
+	l.mu.Unlock()
+	if l.alert != nil {
+		l.alert.stop()
+	}
+	return l.closeErr
 }
 
-func (l *Logger) WaitFlush() {
-	for {
-		if len(l.in) > 0 {
-			time.Sleep(time.Nanosecond * 50)
-		} else {
-			break
-		}
+// syncWriter 对*os.File等支持Sync的输出做fsync, 终端和管道的错误忽略
+func syncWriter(w io.Writer) {
+	if s, ok := w.(interface{ Sync() error }); ok {
+		s.Sync()
 	}
 }
 
@@ -221,51 +374,51 @@ func (l *Logger) Print(v ...interface{}) {
 
 // debug
 func (l *Logger) Debugf(format string, v ...interface{}) {
-	if Ldebug&l.flag != 0 {
+	if l.enabled(Ldebug) {
 		l.Output(Ldebug, 2, fmt.Sprintf(format, v...))
 	}
 }
 
 func (l *Logger) Debug(v string) {
-	if Ldebug&l.flag != 0 {
+	if l.enabled(Ldebug) {
 		l.Output(Ldebug, 2, v)
 	}
 }
 
 // info
 func (l *Logger) Infof(format string, v ...interface{}) {
-	if Linfo&l.flag != 0 {
+	if l.enabled(Linfo) {
 		l.Output(Linfo, 2, fmt.Sprintf(format, v...))
 	}
 }
 func (l *Logger) Info(v string) {
-	if Linfo&l.flag != 0 {
+	if l.enabled(Linfo) {
 		l.Output(Linfo, 2, v)
 	}
 }
 
 // warn
 func (l *Logger) Warnf(format string, v ...interface{}) {
-	if Lwarn&l.flag != 0 {
+	if l.enabled(Lwarn) {
 		l.Output(Lwarn, 2, fmt.Sprintf(format, v...))
 	}
 }
 
 func (l *Logger) Warn(v string) {
-	if Lwarn&l.flag != 0 {
+	if l.enabled(Lwarn) {
 		l.Output(Lwarn, 2, v)
 	}
 }
 
 // error
 func (l *Logger) Errorf(format string, v ...interface{}) {
-	if Lerror&l.flag != 0 {
-		l.Output(Lerror, 2, fmt.Sprintf(format, v...)+CallerStack())
+	if l.enabled(Lerror) {
+		l.Output(Lerror, 2, fmt.Sprintf(format, v...))
 	}
 }
 
 func (l *Logger) Error(v string) {
-	if Lerror&l.flag != 0 {
+	if l.enabled(Lerror) {
 		l.Output(Lerror, 2, v)
 	}
 }
@@ -273,11 +426,13 @@ func (l *Logger) Error(v string) {
 // fatal
 func (l *Logger) Fatalf(format string, v ...interface{}) {
 	l.Output(Lfatal, 2, fmt.Sprintf(format, v...))
+	l.Close()
 	os.Exit(1)
 }
 
 func (l *Logger) Fatal(v string) {
 	l.Output(Lfatal, 2, v)
+	l.Close()
 	os.Exit(1)
 }
 
@@ -289,6 +444,7 @@ func (l *Logger) SetLogDir(dir string) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	l.dir = dir
+	l.applyColor()
 }
 
 func (l *Logger) SetObj(obj string) {
@@ -301,24 +457,44 @@ func (l *Logger) SetOutput(out io.Writer) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	l.out = out
+	l.applyColor()
 }
 
-func (l *Logger) SetLevel(lvl int) {
+func (l *Logger) SetFormatter(f Formatter) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	var i uint
-	for i = 0; i < uint(len(levelMaps)); i++ {
-		if lvl&1 != 0 {
-			break
-		}
-		lvl >>= 1
+	l.formatter = f
+	l.applyColor()
+}
+
+// SetColor 设置颜色模式: ColorAuto, ColorAlways, ColorNever
+func (l *Logger) SetColor(mode int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.color = mode
+	l.applyColor()
+}
+
+// SetColorTheme 设置级别颜色
+func (l *Logger) SetColorTheme(theme ColorTheme) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.theme = theme
+	l.applyColor()
+}
+
+// SetLevel 设置输出的最低级别, 对Named创建的logger只设置该模块及其子模块
+func (l *Logger) SetLevel(lvl int) {
+	if l.module != nil {
+		l.setModuleLevel(l.module, lvl)
+		return
 	}
-	l.flag = (l.flag >> i) | (Lall >> i)
-	l.flag <<= i
+	// lvl最低位及以上的所有级别, lvl为0时不输出
+	atomic.StoreInt32(&l.levels, int32(Lall&^(lowestLevel(lvl)-1)))
 }
 
 //----------------------------------- standard wrapper ---------------------------------
-var Std = New(LogOption{Out: os.Stdout, ChannelLen: 1000, Flag: LstdFlags})
+var Std = New(LogOption{Out: os.Stdout, ChannelLen: 1000, Flag: LstdFlags, StackLevels: Lerror | Lfatal})
 
 // RedirectLogFile 重新定义输出日志文件
 func RedirectLogFile(logfile string, flags int) error {
@@ -326,15 +502,16 @@ func RedirectLogFile(logfile string, flags int) error {
 		logfile = "app.log"
 	}
 
-	f, err := os.OpenFile(logfile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	f, err := OpenFile(logfile)
 	if err != nil {
 		return errors.New("Could not open log file")
 	}
 
 	option := LogOption{
-		Out:        f,
-		Flag:       flags,
-		ChannelLen: 1000,
+		Out:         f,
+		Flag:        flags,
+		ChannelLen:  1000,
+		StackLevels: Lerror | Lfatal,
 	}
 	Std = New(option)
 	return nil
@@ -344,18 +521,17 @@ func RedirectLogFile(logfile string, flags int) error {
 func Reset(logfile, token, secret string, en_ding bool) {
 	if logfile != "" {
 		Flags := Lwarn | Lerror | Lfatal | Ldate | Ltime | Lshortfile // | Ldaily  | Lasync
-		f, _ := os.OpenFile(logfile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
 		option := LogOption{
-			Out:        f,
-			Flag:       Flags,
-			ChannelLen: 1000,
+			Flag:        Flags,
+			ChannelLen:  1000,
+			StackLevels: Lerror | Lfatal,
+		}
+		if f, err := OpenFile(logfile); err == nil {
+			option.Out = f
+		}
+		if en_ding && token != "" && secret != "" {
+			option.Mails = NewRobot(token, secret)
 		}
-		/*
-			if en_ding && token != "" && secret != "" {
-				robot := NewRobot(token, secret)
-				option.Ding = robot
-			}
-		*/
 
 		Std = New(option)
 	}
@@ -370,63 +546,71 @@ func Print(v ...interface{}) {
 }
 
 func Debugf(format string, v ...interface{}) {
-	if Ldebug&Std.flag != 0 {
+	if Std.enabled(Ldebug) {
 		Std.Output(Ldebug, 2, fmt.Sprintf(format, v...))
 	}
 }
 func Debug(v string) {
-	if Ldebug&Std.flag != 0 {
+	if Std.enabled(Ldebug) {
 		Std.Output(Ldebug, 2, v)
 	}
 }
 
 func Infof(format string, v ...interface{}) {
-	if Linfo&Std.flag != 0 {
+	if Std.enabled(Linfo) {
 		Std.Output(Linfo, 2, fmt.Sprintf(format, v...))
 	}
 }
 func Info(v string) {
-	if Linfo&Std.flag != 0 {
+	if Std.enabled(Linfo) {
 		Std.Output(Linfo, 2, v)
 	}
 }
 
 func Warnf(format string, v ...interface{}) {
-	if Lwarn&Std.flag != 0 {
+	if Std.enabled(Lwarn) {
 		Std.Output(Lwarn, 2, fmt.Sprintf(format, v...))
 	}
 }
 
 func Warn(v string) {
-	if Lwarn&Std.flag != 0 {
+	if Std.enabled(Lwarn) {
 		Std.Output(Lwarn, 2, v)
 	}
 }
 
 func Errorf(format string, v ...interface{}) {
-	if Lerror&Std.flag != 0 {
-		Std.Output(Lerror, 2, fmt.Sprintf(format, v...)+"\n"+CallerStack())
+	if Std.enabled(Lerror) {
+		Std.Output(Lerror, 2, fmt.Sprintf(format, v...)+"\n")
 	}
 }
 
 func Error(v string) {
-	if Lerror&Std.flag != 0 {
+	if Std.enabled(Lerror) {
 		Std.Output(Lerror, 2, v)
 	}
 }
 
 func Fatalf(format string, v ...interface{}) {
 	Std.Output(Lfatal, 2, fmt.Sprintf(format, v...))
-	Std.Output(Lfatal, 2, CallerStack())
+	Std.Close()
 	os.Exit(1)
 }
 
 func Fatal(v string) {
 	Std.Output(Lfatal, 2, v)
-	Std.Output(Lfatal, 2, CallerStack())
+	Std.Close()
 	os.Exit(1)
 }
 
+func Flush(ctx context.Context) error {
+	return Std.Flush(ctx)
+}
+
+func Close() error {
+	return Std.Close()
+}
+
 func Breakpoint() {
 	Std.Breakpoint()
 }
@@ -442,6 +626,18 @@ func SetObj(obj string) {
 	Std.SetObj(obj)
 }
 
+func SetColor(mode int) {
+	Std.SetColor(mode)
+}
+
+func With(kv ...interface{}) *Logger {
+	return Std.With(kv...)
+}
+
+func WithFields(fields Fields) *Logger {
+	return Std.WithFields(fields)
+}
+
 //-----------------------------
 
 // Cheap inteeger to fixed-width decimal ASCII. Give a nagative width to avoid zero-padding.
@@ -461,33 +657,9 @@ func itoa(buf *[]byte, i int, wid int) {
 	*buf = append(*buf, b[bp:]...)
 }
 
-const (
-	Gray = uint8(iota + 90)
-	Red
-	Green
-	Yellow
-	Blue
-	Magenta
-)
-
-func getColorLevel(level string) string {
-	level = strings.ToUpper(level)
-	switch level {
-	case "DEBUG":
-		return fmt.Sprintf("\033[%dm[%5s]\033[0m", Green, level)
-	case "INFO":
-		return fmt.Sprintf("\033[%dm[%5s]\033[0m", Blue, level)
-	case "WARN":
-		return fmt.Sprintf("\033[%dm[%5s]\033[0m", Magenta, level)
-	case "ERROR":
-		return fmt.Sprintf("\033[%dm[%5s]\033[0m", Yellow, level)
-	case "FATAL":
-		return fmt.Sprintf("\033[%dm[%5s]\033[0m", Red, level)
-	default:
-		return level
-	}
-}
-
+// CallerStack 返回调用者的完整调用栈字符串
+//
+// Deprecated: 使用LogOption.StackLevels或SetStack, 记录附带过滤后的Stack
 func CallerStack() string {
 	var caller_str string
 	for skip := 2; ; skip++ {
@@ -1,6 +1,11 @@
 package logd
 
-import "testing"
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
 
 func TestAll(t *testing.T) {
 	Printf("Print: foo\n")
@@ -28,3 +33,47 @@ func TestAll(t *testing.T) {
 	Errorf("Error: foo")
 	Error("Error: foo")
 }
+
+func TestWith(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(LogOption{Out: &buf, Flag: Lall | Lshortfile})
+	l.With("user", "tom", "order", 12).WithFields(Fields{"msg": "a b"}).Infof("paid\n")
+
+	if got := buf.String(); !strings.HasSuffix(got, `: paid user=tom order=12 msg="a b"`+"\n") {
+		t.Errorf("unexpected output: %q", got)
+	}
+}
+
+type upperFormatter struct{}
+
+func (upperFormatter) Format(r *Record) []byte {
+	return []byte(levelMaps[r.Level] + "|" + r.Obj + "|" + strings.ToUpper(r.Message))
+}
+
+func TestFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(LogOption{Out: &buf, Flag: Lall, Formatter: upperFormatter{}})
+	l.SetObj("svc")
+	l.Warn("disk full")
+	if got := buf.String(); got != "WARN|svc|DISK FULL" {
+		t.Errorf("unexpected output: %q", got)
+	}
+}
+
+// 输出与SetObj, SetFormatter并发, 用 -race 检查
+func TestSetters_Concurrent(t *testing.T) {
+	l := New(LogOption{Out: ioutil.Discard, Flag: Lall})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			l.Info("x\n")
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		l.SetObj("svc")
+		l.SetFormatter(&JSONFormatter{})
+		l.SetFormatter(&TextFormatter{})
+	}
+	<-done
+}
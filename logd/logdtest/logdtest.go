@@ -0,0 +1,206 @@
+// Package logdtest 用于在测试中记录和检查logd输出的日志
+//
+//	func TestX(t *testing.T) {
+//		logdtest.ReplaceStd(t, 0)
+//		doSomething()
+//		logdtest.AssertLogged(t, logd.Lerror, "connect failed")
+//	}
+package logdtest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yahao333/utils/logd"
+)
+
+// Recorder 作为logd.Sink记录每条日志, 可并发使用
+type Recorder struct {
+	mu      sync.Mutex
+	records []logd.Record
+}
+
+func (r *Recorder) Enabled(lvl int) bool {
+	return true
+}
+
+func (r *Recorder) WriteRecord(rec *logd.Record) error {
+	r.mu.Lock()
+	r.records = append(r.records, *rec)
+	r.mu.Unlock()
+	return nil
+}
+
+// Records 返回已记录日志的副本
+func (r *Recorder) Records() []logd.Record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]logd.Record(nil), r.records...)
+}
+
+// Find 返回级别为lvl且消息包含substr的日志, lvl为0时匹配所有级别
+func (r *Recorder) Find(lvl int, substr string) []logd.Record {
+	var found []logd.Record
+	for _, rec := range r.Records() {
+		if (lvl == 0 || rec.Level == lvl) && strings.Contains(rec.Message, substr) {
+			found = append(found, rec)
+		}
+	}
+	return found
+}
+
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	r.records = nil
+	r.mu.Unlock()
+}
+
+// AssertLogged 没有级别为lvl且消息包含substr的日志时测试失败
+func (r *Recorder) AssertLogged(t testing.TB, lvl int, substr string) {
+	t.Helper()
+	if len(r.Find(lvl, substr)) == 0 {
+		t.Errorf("no %s log containing %q, got:\n%s", levelName(lvl), substr, r.dump())
+	}
+}
+
+// AssertNotLogged 有级别为lvl且消息包含substr的日志时测试失败
+func (r *Recorder) AssertNotLogged(t testing.TB, lvl int, substr string) {
+	t.Helper()
+	if found := r.Find(lvl, substr); len(found) > 0 {
+		t.Errorf("unexpected %s log containing %q: %s:%d: %s",
+			levelName(lvl), substr, found[0].File, found[0].Line, strings.TrimRight(found[0].Message, "\n"))
+	}
+}
+
+func (r *Recorder) dump() string {
+	var b strings.Builder
+	for _, rec := range r.Records() {
+		fmt.Fprintf(&b, "\t%s %s:%d: %s", logd.LevelName(rec.Level), rec.File, rec.Line,
+			strings.TrimRight(rec.Message, "\n"))
+		for _, f := range rec.Fields {
+			fmt.Fprintf(&b, " %s=%v", f.Key, f.Value)
+		}
+		b.WriteByte('\n')
+	}
+	if b.Len() == 0 {
+		return "\t(none)\n"
+	}
+	return b.String()
+}
+
+func levelName(lvl int) string {
+	if lvl == 0 {
+		return "any"
+	}
+	return logd.LevelName(lvl)
+}
+
+// New 返回只输出到Recorder的同步logger, flag为0时记录所有级别
+func New(flag int) (*logd.Logger, *Recorder) {
+	if flag == 0 {
+		flag = logd.Lall
+	}
+	rec := &Recorder{}
+	l := logd.New(logd.LogOption{Flag: flag &^ logd.LAsync, Sinks: []logd.Sink{rec}})
+	return l, rec
+}
+
+// replaced ReplaceStd保存的一次替换
+type replaced struct {
+	t    testing.TB
+	name string
+	rec  *Recorder
+	old  *logd.Logger
+}
+
+var (
+	stdMu sync.Mutex  // 不同测试依次替换Std, 由最外层的ReplaceStd持有到测试结束
+	mu    sync.Mutex  // 保护stack
+	stack []*replaced // 当前的替换, 同一测试及其子测试中可以嵌套
+)
+
+// within name是否为owner或其子测试
+func within(name, owner string) bool {
+	return name == owner || strings.HasPrefix(name, owner+"/")
+}
+
+// ReplaceStd 将logd.Std替换为New(flag)创建的logger, 测试结束时恢复.
+// 不同测试同时调用时依次等待; 已替换Std的测试及其子测试中可以再次调用, 结束时逐层恢复.
+// 并行的兄弟子测试不能同时替换, 会直接失败.
+func ReplaceStd(t testing.TB, flag int) *Recorder {
+	t.Helper()
+	name := t.Name()
+	mu.Lock()
+	if n := len(stack); n > 0 && within(name, stack[0].name) {
+		if !within(name, stack[n-1].name) {
+			top := stack[n-1].name
+			mu.Unlock()
+			t.Fatalf("logdtest: ReplaceStd in %s while %s has replaced Std", name, top)
+		}
+	} else {
+		mu.Unlock()
+		stdMu.Lock()
+		mu.Lock()
+	}
+	l, rec := New(flag)
+	r := &replaced{t: t, name: name, rec: rec, old: logd.Std}
+	logd.Std = l
+	stack = append(stack, r)
+	outer := len(stack) == 1
+	mu.Unlock()
+
+	t.Cleanup(func() {
+		mu.Lock()
+		if stack[len(stack)-1] != r {
+			t.Errorf("logdtest: ReplaceStd in %s restored out of order", name)
+		}
+		for i := len(stack) - 1; i >= 0; i-- {
+			if stack[i] == r {
+				stack = append(stack[:i], stack[i+1:]...)
+				break
+			}
+		}
+		logd.Std = r.old
+		mu.Unlock()
+		if outer {
+			stdMu.Unlock()
+		}
+	})
+	return rec
+}
+
+// AssertLogged 检查ReplaceStd记录的日志, 没有级别为lvl且消息包含substr的日志时测试失败
+func AssertLogged(t testing.TB, lvl int, substr string) {
+	t.Helper()
+	recorder(t).AssertLogged(t, lvl, substr)
+}
+
+// AssertNotLogged 检查ReplaceStd记录的日志, 有级别为lvl且消息包含substr的日志时测试失败
+func AssertNotLogged(t testing.TB, lvl int, substr string) {
+	t.Helper()
+	recorder(t).AssertNotLogged(t, lvl, substr)
+}
+
+func recorder(t testing.TB) *Recorder {
+	t.Helper()
+	mu.Lock()
+	var rec *Recorder
+	// 本测试的替换, 没有时使用最近的上级测试的替换
+	for i := len(stack) - 1; i >= 0 && rec == nil; i-- {
+		if stack[i].t == t {
+			rec = stack[i].rec
+		}
+	}
+	for i := len(stack) - 1; i >= 0 && rec == nil; i-- {
+		if within(t.Name(), stack[i].name) {
+			rec = stack[i].rec
+		}
+	}
+	mu.Unlock()
+	if rec == nil {
+		t.Fatal("logdtest: ReplaceStd not called in this test")
+	}
+	return rec
+}
@@ -0,0 +1,93 @@
+package logdtest
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/yahao333/utils/logd"
+)
+
+func TestReplaceStd(t *testing.T) {
+	old := logd.Std
+	t.Run("replaced", func(t *testing.T) {
+		rec := ReplaceStd(t, 0)
+		logd.Std.With("id", 3).Error("connect failed\n")
+		logd.Debug("retry")
+
+		AssertLogged(t, logd.Lerror, "connect")
+		AssertLogged(t, logd.Ldebug, "retry")
+		AssertNotLogged(t, logd.Lwarn, "connect")
+
+		records := rec.Records()
+		if len(records) != 2 {
+			t.Fatalf("records = %d", len(records))
+		}
+		r := records[0]
+		if filepath.Base(r.File) != "logdtest_test.go" || len(r.Fields) != 1 || r.Fields[0].Value != 3 {
+			t.Fatalf("record = %+v", r)
+		}
+	})
+	if logd.Std != old {
+		t.Fatal("Std not restored")
+	}
+}
+
+type fakeT struct {
+	testing.TB
+	failed string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.failed = fmt.Sprintf(format, args...)
+}
+
+func TestAssertLogged_Fail(t *testing.T) {
+	l, rec := New(logd.Lwarn | logd.Lerror | logd.Lfatal)
+	l.Info("ignored")
+	l.Warn("disk almost full")
+
+	ft := &fakeT{TB: t}
+	rec.AssertLogged(ft, logd.Lerror, "disk")
+	if ft.failed == "" {
+		t.Fatal("AssertLogged passed on wrong level")
+	}
+	ft.failed = ""
+	rec.AssertLogged(ft, 0, "disk")
+	rec.AssertNotLogged(ft, logd.Linfo, "ignored")
+	if ft.failed != "" {
+		t.Fatal(ft.failed)
+	}
+}
+
+func TestReplaceStd_Nested(t *testing.T) {
+	old := logd.Std
+	outer := ReplaceStd(t, 0)
+	outerStd := logd.Std
+	logd.Warn("outer")
+
+	t.Run("inner", func(t *testing.T) {
+		inner := ReplaceStd(t, 0)
+		logd.Warn("inner")
+		AssertLogged(t, logd.Lwarn, "inner")
+		AssertNotLogged(t, logd.Lwarn, "outer")
+		if len(inner.Records()) != 1 {
+			t.Fatalf("inner records = %d", len(inner.Records()))
+		}
+	})
+	if logd.Std != outerStd {
+		t.Fatal("inner ReplaceStd not restored")
+	}
+	t.Run("uses parent", func(t *testing.T) {
+		logd.Warn("from subtest")
+		AssertLogged(t, logd.Lwarn, "from subtest")
+	})
+	if n := len(outer.Records()); n != 2 {
+		t.Fatalf("outer records = %d", n)
+	}
+	if logd.Std == old {
+		t.Fatal("Std restored before test end")
+	}
+}
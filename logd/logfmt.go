@@ -0,0 +1,94 @@
+package logd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// LogfmtFormatter 每条记录输出一行logfmt:
+// ts=2006-01-02T15:04:05.000000+08:00 level=warn obj=api logger=db caller=x.go:12 msg="..." key=value
+// Flag中的LUTC控制时区, Lshortfile/Llongfile控制caller
+type LogfmtFormatter struct {
+	Flag int
+}
+
+func (f *LogfmtFormatter) Format(r *Record) []byte {
+	t := r.Time
+	if f.Flag&LUTC != 0 {
+		t = t.UTC()
+	}
+	buf := make([]byte, 0, 128+len(r.Message))
+	buf = append(buf, "ts="...)
+	buf = t.AppendFormat(buf, "2006-01-02T15:04:05.000000Z07:00")
+	buf = append(buf, " level="...)
+	buf = append(buf, strings.ToLower(levelMaps[r.Level])...)
+	buf = append(buf, " obj="...)
+	appendLogfmtValue(&buf, r.Obj)
+	if r.Name != "" {
+		buf = append(buf, " logger="...)
+		appendLogfmtValue(&buf, r.Name)
+	}
+	if f.Flag&(Lshortfile|Llongfile) != 0 {
+		file := r.File
+		if f.Flag&Lshortfile != 0 {
+			file = shortFile(file)
+		}
+		buf = append(buf, " caller="...)
+		appendLogfmtValue(&buf, file+":"+strconv.Itoa(r.Line))
+	}
+	buf = append(buf, " msg="...)
+	appendLogfmtValue(&buf, strings.TrimRight(r.Message, "\n"))
+	for _, field := range r.Fields {
+		buf = append(buf, ' ')
+		appendLogfmtKey(&buf, field.Key)
+		buf = append(buf, '=')
+		if v, ok := field.Value.(string); ok {
+			appendLogfmtValue(&buf, v)
+		} else {
+			appendLogfmtValue(&buf, fmt.Sprint(field.Value))
+		}
+	}
+	if len(r.Stack) > 0 {
+		buf = append(buf, " stack="...)
+		appendLogfmtValue(&buf, r.Stack.oneLine(f.Flag&Lshortfile != 0))
+	}
+	return append(buf, '\n')
+}
+
+// appendLogfmtKey key中的空白, '=' 和 '"' 替换为 '_'
+func appendLogfmtKey(buf *[]byte, key string) {
+	if key == "" {
+		key = "_"
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c <= ' ' || c == '=' || c == '"' || c == 0x7f {
+			c = '_'
+		}
+		*buf = append(*buf, c)
+	}
+}
+
+// appendLogfmtValue 值为空或包含空白, '=', '"', 控制字符, 非法utf8时加引号
+func appendLogfmtValue(buf *[]byte, s string) {
+	if logfmtNeedsQuote(s) {
+		*buf = strconv.AppendQuote(*buf, s)
+		return
+	}
+	*buf = append(*buf, s...)
+}
+
+func logfmtNeedsQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c == '=' || c == '"' || c == '\\' || c == 0x7f {
+			return true
+		}
+	}
+	return !utf8.ValidString(s)
+}
@@ -0,0 +1,29 @@
+package logd
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestLogfmtFormat(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(LogOption{Out: &buf, Flag: Lall | Lshortfile, Format: FormatLogfmt})
+	l.SetObj("api")
+	l.With("user", "tom", "q", "a=b", "empty", "", "bad key", 1).Warnf("disk \"full\"\n")
+
+	re := regexp.MustCompile(`^ts=\S+ level=warn obj=api caller=logfmt_test\.go:\d+ msg="disk \\"full\\"" user=tom q="a=b" empty="" bad_key=1\n$`)
+	if got := buf.String(); !re.MatchString(got) {
+		t.Errorf("unexpected output: %q", got)
+	}
+}
+
+func TestLogfmtFormat_NilReceiver(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(LogOption{Out: &buf, Flag: Lall, Format: FormatLogfmt})
+	l.With("err", (*nilErr)(nil)).Error("nil error\n")
+	if !strings.HasSuffix(buf.String(), ` msg="nil error" err=<nil>`+"\n") {
+		t.Fatalf("got %q", buf.String())
+	}
+}
@@ -1,75 +1,219 @@
 package logd
 
 import (
+	"bytes"
+	"crypto/rand"
 	"crypto/tls"
 	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
 	"net/smtp"
+	"net/textproto"
 	"strings"
+	"time"
 )
 
 type Emailer interface {
 	SendMail(fromname string, msg []byte) error
 }
 
+// Smtp连接方式
+const (
+	TLSImplicit = iota // 直接建立TLS连接, 一般为465端口
+	TLSStartTLS        // 明文连接后STARTTLS升级, 一般为587端口
+	TLSNone            // 不加密, 用于内网中继
+)
+
 type Smtp struct {
-	From    string   // 发件箱: xx@163.com
-	Key     string   // 发件密钥: sdkfjakdfj
-	Host    string   // 主机地址： smtp.example.com
-	Port    string   // 主机端口: 465
-	To      []string // 发送给: xxx@163.com
-	Subject string   // 邮件标题: 告警[logd]
+	From      string        // 发件箱: xx@163.com
+	Key       string        // 发件密钥: sdkfjakdfj, 为空时不认证
+	User      string        // 认证用户名, 为空使用From
+	Host      string        // 主机地址： smtp.example.com
+	Port      string        // 主机端口: 465
+	To        []string      // 发送给: xxx@163.com
+	Subject   string        // 邮件标题: 告警[logd]
+	TLS       int           // 连接方式: TLSImplicit(默认), TLSStartTLS, TLSNone
+	TLSConfig *tls.Config   // 为空时只设置ServerName
+	HTML      bool          // 同时发送HTML正文, multipart/alternative
+	Timeout   time.Duration // 连接和整个发送过程的超时, 默认30秒
 }
 
+const defaultSmtpTimeout = 30 * time.Second
+
 func (s *Smtp) SendMail(fromname string, msg []byte) error {
-	conn, err := tls.Dial("tcp", s.Host+":"+s.Port, nil)
+	client, err := s.dial()
 	if err != nil {
 		return err
 	}
+	defer client.Close()
 
-	client, err := smtp.NewClient(conn, s.Host)
-	if err != nil {
-		return err
-	}
 	// 获取授权
-	auth := smtp.PlainAuth("", s.From, s.Key, s.Host)
-	if err = client.Auth(auth); err != nil {
-		return err
+	if s.Key != "" {
+		user := s.User
+		if user == "" {
+			user = s.From
+		}
+		if err = client.Auth(smtp.PlainAuth("", user, s.Key, s.Host)); err != nil {
+			return err
+		}
 	}
 
 	if err = client.Mail(s.From); err != nil {
 		return err
 	}
 
-	str := fmt.Sprint(
-		"To:", strings.Join(s.To, ","),
-		"\r\nFrom:", fmt.Sprintf("%s<%s>", fromname, s.From),
-		"\r\nSubject:", s.Subject,
-		"\r\n", "Content-Type:text/plain;charset=UTF-8",
-		"\r\n\r\n",
-	)
-	data := make([]byte, len(str)+len(msg))
-	copy(data, []byte(str))
-	copy(data[len(str):], msg)
-
 	// RCPT
-	for _, d := range s.To{
-		if err := client.Rcpt(d); err != nil{
-			return  err
+	for _, d := range s.To {
+		if err := client.Rcpt(d); err != nil {
+			return err
 		}
 	}
 
+	data, err := s.message(fromname, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
 	// 获取WriteCloser
 	wc, err := client.Data()
-	if err != nil{
+	if err != nil {
 		return err
 	}
 
 	// 写入数据
-	_, err = wc.Write(data)
-	if err != nil{
+	if _, err = wc.Write(data); err != nil {
+		return err
+	}
+	if err = wc.Close(); err != nil {
 		return err
 	}
-	wc.Close()
 
 	return client.Quit()
 }
+
+// dial 按TLS模式建立连接, 连接的deadline为Timeout之后
+func (s *Smtp) dial() (*smtp.Client, error) {
+	addr := net.JoinHostPort(s.Host, s.Port)
+	config := s.TLSConfig
+	if config == nil {
+		config = &tls.Config{ServerName: s.Host}
+	}
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = defaultSmtpTimeout
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if s.TLS == TLSImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, config)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if s.TLS == TLSStartTLS {
+		if err := client.StartTLS(config); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// message 生成带MIME头的邮件, HTML为true时为text/plain和text/html的multipart/alternative
+func (s *Smtp) message(fromname string, msg []byte, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	from := mail.Address{Name: fromname, Address: s.From}
+	header := textproto.MIMEHeader{}
+	header.Set("From", from.String())
+	header.Set("To", strings.Join(s.To, ", "))
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", s.Subject))
+	header.Set("Date", now.Format(time.RFC1123Z))
+	header.Set("Message-Id", messageID(s.From, now))
+	header.Set("MIME-Version", "1.0")
+
+	if !s.HTML {
+		header.Set("Content-Type", "text/plain; charset=UTF-8")
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		writeHeader(&buf, header)
+		if err := writeQuotedPrintable(&buf, msg); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	header.Set("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	writeHeader(&buf, header)
+
+	text, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintable(text, msg); err != nil {
+		return nil, err
+	}
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	page := "<html><body><pre>" + html.EscapeString(string(msg)) + "</pre></body></html>"
+	if err := writeQuotedPrintable(part, []byte(page)); err != nil {
+		return nil, err
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	buf.Write(body.Bytes())
+	return buf.Bytes(), nil
+}
+
+// 按固定顺序写邮件头
+func writeHeader(buf *bytes.Buffer, header textproto.MIMEHeader) {
+	for _, k := range []string{"From", "To", "Subject", "Date", "Message-Id", "MIME-Version",
+		"Content-Type", "Content-Transfer-Encoding"} {
+		if v := header.Get(k); v != "" {
+			fmt.Fprintf(buf, "%s: %s\r\n", k, v)
+		}
+	}
+	buf.WriteString("\r\n")
+}
+
+func writeQuotedPrintable(w io.Writer, data []byte) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write(data); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// messageID <时间.随机数@发件域名>
+func messageID(from string, now time.Time) string {
+	domain := "localhost"
+	if i := strings.LastIndexByte(from, '@'); i >= 0 {
+		domain = from[i+1:]
+	}
+	var b [8]byte
+	rand.Read(b[:])
+	return fmt.Sprintf("<%d.%x@%s>", now.UnixNano(), b, domain)
+}
@@ -1,6 +1,16 @@
 package logd
 
-import "testing"
+import (
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestSmtp_SendMail(t *testing.T) {
 	s := &Smtp{
@@ -17,3 +27,116 @@ func TestSmtp_SendMail(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+// fakeSmtp 最简单的无认证SMTP服务, 返回收到的DATA
+func fakeSmtp(t *testing.T) (addr string, data <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := make(chan string, 1)
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tc := textproto.NewConn(conn)
+		tc.PrintfLine("220 fake ESMTP")
+		for {
+			line, err := tc.ReadLine()
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+			case "EHLO", "HELO":
+				tc.PrintfLine("250 fake")
+			case "DATA":
+				tc.PrintfLine("354 go ahead")
+				body, _ := tc.ReadDotBytes()
+				ch <- string(body)
+				tc.PrintfLine("250 ok")
+			case "QUIT":
+				tc.PrintfLine("221 bye")
+				return
+			default:
+				tc.PrintfLine("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().String(), ch
+}
+
+func TestSmtp_Relay(t *testing.T) {
+	addr, data := fakeSmtp(t)
+	host, port, _ := net.SplitHostPort(addr)
+	s := &Smtp{
+		From:    "alert@example.com",
+		Host:    host,
+		Port:    port,
+		To:      []string{"ops@example.com"},
+		Subject: "告警[logd]",
+		TLS:     TLSNone,
+		HTML:    true,
+	}
+	if err := s.SendMail("api", []byte("disk <full>\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(<-data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "告警[logd]" || msg.Header.Get("Date") == "" || msg.Header.Get("Message-Id") == "" ||
+		msg.Header.Get("From") != `"api" <alert@example.com>` {
+		t.Errorf("unexpected header %v", msg.Header)
+	}
+	_, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	var parts []string
+	for {
+		p, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		body, _ := ioutil.ReadAll(p)
+		parts = append(parts, p.Header.Get("Content-Type")+": "+string(body))
+	}
+	want := []string{
+		"text/plain; charset=UTF-8: disk <full>\n",
+		"text/html; charset=UTF-8: <html><body><pre>disk &lt;full&gt;\n</pre></body></html>",
+	}
+	if strings.Join(parts, "|") != strings.Join(want, "|") {
+		t.Errorf("parts = %q", parts)
+	}
+}
+
+func TestSmtp_Timeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// 接受连接但不发送问候
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	s := &Smtp{From: "a@example.com", Host: host, Port: port, To: []string{"b@example.com"},
+		TLS: TLSNone, Timeout: 100 * time.Millisecond}
+	start := time.Now()
+	if err := s.SendMail("api", []byte("x")); err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("SendMail blocked for %s", d)
+	}
+}
@@ -0,0 +1,100 @@
+package logd
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// module Named创建的模块, 级别为0时继承上级模块, 最终继承根logger的flag
+type module struct {
+	name   string
+	parent *module
+	level  int32 // 最低输出级别, 原子操作
+}
+
+// Named 返回名为name的子模块logger, 已有模块名时以 "." 连接, 如
+// Std.Named("db").Named("mongo") 为 "db.mongo".
+// 子模块logger与根logger共享输出, 有自己的级别, 未设置时继承上级模块.
+func (l *Logger) Named(name string) *Logger {
+	if l.module != nil {
+		name = l.module.name + "." + name
+	}
+	l.mu.Lock()
+	m := l.lookupModule(name)
+	l.mu.Unlock()
+	return &Logger{core: l.core, fields: l.fields, module: m}
+}
+
+// Name 返回模块名, 根logger为空
+func (l *Logger) Name() string {
+	if l.module == nil {
+		return ""
+	}
+	return l.module.name
+}
+
+// lookupModule 获取或创建模块及其上级模块, 调用时需持有l.mu
+func (l *Logger) lookupModule(name string) *module {
+	if m, ok := l.modules[name]; ok {
+		return m
+	}
+	if l.modules == nil {
+		l.modules = make(map[string]*module)
+	}
+	m := &module{name: name}
+	if i := strings.LastIndexByte(name, '.'); i > 0 {
+		m.parent = l.lookupModule(name[:i])
+	}
+	l.modules[name] = m
+	return m
+}
+
+// enabled 级别lvl是否输出, 从模块向上查找设置了级别的模块, 都未设置时使用根logger的levels
+func (l *Logger) enabled(lvl int) bool {
+	for m := l.module; m != nil; m = m.parent {
+		if min := atomic.LoadInt32(&m.level); min != 0 {
+			return lvl >= int(min)
+		}
+	}
+	return int(atomic.LoadInt32(&l.levels))&lvl != 0
+}
+
+// lowestLevel 返回lvl中最低的级别位, 如 Lwarn|Lerror 返回 Lwarn
+func lowestLevel(lvl int) int {
+	return lvl & -lvl & Lall
+}
+
+func (l *Logger) setModuleLevel(m *module, lvl int) {
+	atomic.StoreInt32(&m.level, int32(lowestLevel(lvl)))
+}
+
+// SetModuleLevel 运行时设置模块级别, 模块不存在时创建; lvl为0时恢复继承上级
+func (l *Logger) SetModuleLevel(name string, lvl int) {
+	l.mu.Lock()
+	m := l.lookupModule(name)
+	l.mu.Unlock()
+	l.setModuleLevel(m, lvl)
+}
+
+// ModuleLevels 返回所有模块单独设置的级别, 未设置的模块为0
+func (l *Logger) ModuleLevels() map[string]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	levels := make(map[string]int, len(l.modules))
+	for name, m := range l.modules {
+		levels[name] = int(atomic.LoadInt32(&m.level))
+	}
+	return levels
+}
+
+func Named(name string) *Logger {
+	return Std.Named(name)
+}
+
+func SetModuleLevel(name string, lvl int) {
+	Std.SetModuleLevel(name, lvl)
+}
+
+func ModuleLevels() map[string]int {
+	return Std.ModuleLevels()
+}
@@ -0,0 +1,43 @@
+package logd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNamed(t *testing.T) {
+	var buf bytes.Buffer
+	root := New(LogOption{Out: &buf, Flag: Lwarn | Lerror | Lfatal})
+	db := root.Named("db")
+	mongo := db.Named("mongo").With("coll", "users")
+	http := root.Named("http")
+
+	db.SetLevel(Ldebug)
+	root.SetModuleLevel("http", Lerror)
+
+	root.Info("root info\n")     // 根logger为warn, 不输出
+	mongo.Debug("mongo debug\n") // 继承db的debug
+	http.Warn("http warn\n")     // http为error, 不输出
+	http.Error("http error\n")
+
+	want := "[DEBUG] [db.mongo] mongo debug coll=users\n[ERROR] [http] http error\n"
+	if got := buf.String(); got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	root.SetModuleLevel("db", 0)
+	mongo.Info("mongo info\n")
+	if got := buf.String(); got != "" {
+		t.Errorf("db level reset but got %q", got)
+	}
+
+	levels := root.ModuleLevels()
+	if levels["http"] != Lerror || levels["db"] != 0 || len(levels) != 3 {
+		t.Errorf("levels = %v", levels)
+	}
+	if mongo.Name() != "db.mongo" || !strings.HasPrefix(mongo.Named("x").Name(), "db.mongo.") {
+		t.Errorf("names %q %q", mongo.Name(), mongo.Named("x").Name())
+	}
+}
@@ -0,0 +1,72 @@
+package logd
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// 异步channel满时的策略
+const (
+	OverflowBlock      = iota // 阻塞直到有空位
+	OverflowDropNewest        // 丢弃当前日志
+	OverflowDropOldest        // 丢弃channel中最早的日志
+	OverflowTimeout           // 最多阻塞BlockTimeout, 超时丢弃当前日志
+)
+
+// send 发送到异步channel, logger已关闭时返回false.
+// 按overflow策略被丢弃的日志计入dropped, 返回true.
+func (l *Logger) send(e entry) bool {
+	l.closeMu.RLock()
+	defer l.closeMu.RUnlock()
+	if l.closed {
+		return false
+	}
+	switch l.overflow {
+	case OverflowDropNewest:
+		select {
+		case l.in <- e:
+		default:
+			l.drop(e.lvl)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case l.in <- e:
+				return true
+			default:
+			}
+			select {
+			case old := <-l.in:
+				l.drop(old.lvl)
+			default:
+			}
+		}
+	case OverflowTimeout:
+		timer := time.NewTimer(l.blockTimeout)
+		select {
+		case l.in <- e:
+		case <-timer.C:
+			l.drop(e.lvl)
+		}
+		timer.Stop()
+	default:
+		l.in <- e
+	}
+	return true
+}
+
+func (l *Logger) drop(lvl int) {
+	if i := bits.TrailingZeros(uint(lvl)); i < len(l.dropped) {
+		atomic.AddUint64(&l.dropped[i], 1)
+	}
+}
+
+// Dropped 返回因异步channel满被丢弃的日志数, key为Ldebug...Lfatal
+func (l *Logger) Dropped() map[int]uint64 {
+	m := make(map[int]uint64, len(l.dropped))
+	for i := range l.dropped {
+		m[1<<uint(i)] = atomic.LoadUint64(&l.dropped[i])
+	}
+	return m
+}
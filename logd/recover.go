@@ -0,0 +1,127 @@
+package logd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"time"
+)
+
+// panicFlushTimeout 输出panic后等待异步日志写完的最长时间
+const panicFlushTimeout = 5 * time.Second
+
+// Recover 在defer中直接调用, 捕获panic并输出panic值和调用栈, 等待异步日志写完,
+// 设置了Repanic时重新panic:
+//
+//	defer logd.Recover()
+func (l *Logger) Recover() {
+	if v := recover(); v != nil {
+		l.handlePanic(v)
+	}
+}
+
+// Go 在新goroutine中执行f, f panic时同Recover, 未设置Repanic时只结束该goroutine
+func (l *Logger) Go(f func()) {
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				l.handlePanic(v)
+			}
+		}()
+		f()
+	}()
+}
+
+// RecoverHandler HTTP中间件, handler panic时输出panic值、调用栈、请求方法和URL并返回500.
+// http.ErrAbortHandler不输出, 直接向上panic.
+func (l *Logger) RecoverHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			l.Ctx(r.Context()).With("method", r.Method, "url", r.URL.String()).handlePanic(v)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handlePanic 必须由调用recover的defer函数直接调用, 调用栈从panic处开始.
+// panic总是输出, 不受级别和采样限制, 调用栈不受StackLevels限制.
+func (l *Logger) handlePanic(v interface{}) {
+	l.mu.Lock()
+	lvl, repanic := l.panicLevel, l.repanic
+	l.mu.Unlock()
+	if lvl != Lfatal {
+		lvl = Lerror
+	}
+	// 跳过handlePanic和defer函数, runtime.gopanic等被过滤
+	stack := CaptureStack(2, l.stackDepth, l.stackModule)
+	var file string
+	var line int
+	if len(stack) > 0 {
+		file, line = stack[0].File, stack[0].Line
+	} else {
+		_, file, line, _ = runtime.Caller(2)
+	}
+	l.write(&Record{
+		Time:    time.Now(),
+		Level:   lvl,
+		Obj:     l.getObj(),
+		File:    file,
+		Line:    line,
+		Message: fmt.Sprintf("panic: %v\n", v),
+		Fields:  l.fields,
+		Name:    l.Name(),
+		Stack:   stack,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), panicFlushTimeout)
+	l.Flush(ctx)
+	cancel()
+	if repanic {
+		panic(v)
+	}
+}
+
+// SetPanicLevel 设置Recover等输出panic的级别: Lerror或Lfatal
+func (l *Logger) SetPanicLevel(lvl int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.panicLevel = lvl
+}
+
+// SetRepanic 设置Recover等输出后是否重新panic
+func (l *Logger) SetRepanic(repanic bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.repanic = repanic
+}
+
+// Recover 同Logger.Recover, 输出到Std, 必须在defer中直接调用
+func Recover() {
+	if v := recover(); v != nil {
+		Std.handlePanic(v)
+	}
+}
+
+func Go(f func()) {
+	Std.Go(f)
+}
+
+func RecoverHandler(next http.Handler) http.Handler {
+	return Std.RecoverHandler(next)
+}
+
+func SetPanicLevel(lvl int) {
+	Std.SetPanicLevel(lvl)
+}
+
+func SetRepanic(repanic bool) {
+	Std.SetRepanic(repanic)
+}
@@ -0,0 +1,83 @@
+package logd
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func panicky() {
+	panic("boom")
+}
+
+func TestRecover(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(LogOption{Out: &buf, Flag: Lfatal | Lshortfile | LAsync, ChannelLen: 10})
+	defer l.Close()
+	func() {
+		defer l.Recover()
+		panicky()
+	}()
+	// Recover返回前已经Flush异步日志
+	out := buf.String()
+	if !strings.HasPrefix(out, "[ERROR] recover_test.go:13: panic: boom\n\t") ||
+		!strings.Contains(out, "logd.panicky\n") || strings.Contains(out, "runtime.gopanic") {
+		t.Fatalf("got %q", out)
+	}
+}
+
+func TestRecover_Repanic(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(LogOption{Out: &buf, Flag: Lall, PanicLevel: Lfatal, Repanic: true})
+	defer func() {
+		if v := recover(); v != "boom" {
+			t.Fatalf("recovered %v", v)
+		}
+		if !strings.HasPrefix(buf.String(), "[FATAL] panic: boom\n") {
+			t.Fatalf("got %q", buf.String())
+		}
+	}()
+	defer l.Recover()
+	panicky()
+}
+
+// chanWriter 每次写入发送到channel
+type chanWriter chan string
+
+func (c chanWriter) Write(p []byte) (int, error) {
+	c <- string(p)
+	return len(p), nil
+}
+
+func TestGo(t *testing.T) {
+	out := make(chanWriter, 1)
+	l := New(LogOption{Out: out, Flag: Lall})
+	l.Go(panicky)
+	select {
+	case got := <-out:
+		if !strings.HasPrefix(got, "[ERROR] panic: boom\n") {
+			t.Fatalf("got %q", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("panic not logged")
+	}
+}
+
+func TestRecoverHandler(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(LogOption{Out: &buf, Flag: Lall})
+	h := l.RecoverHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panicky()
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/x?id=1", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("code = %d", w.Code)
+	}
+	if !strings.HasPrefix(buf.String(), "[ERROR] panic: boom method=GET url=\"/x?id=1\"\n") {
+		t.Fatalf("got %q", buf.String())
+	}
+}
@@ -0,0 +1,298 @@
+package logd
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// logFile 异步输出时按天和大小切分的日志文件, 只在receive中使用
+type logFile struct {
+	file  *os.File
+	day   string // 2006-01-02
+	index int    // 当天的分段序号, 0为 obj_2006-01-02.log, n为 obj_2006-01-02.n.log
+	size  int64  // 当前分段已写入字节数
+	fail  bool   // 上次写入失败, 已报告, 恢复前不重复报告
+}
+
+func (lf *logFile) sync() {
+	if lf.file != nil {
+		lf.file.Sync()
+	}
+}
+
+// close fsync并关闭当前文件
+func (lf *logFile) close() error {
+	if lf.file == nil {
+		return nil
+	}
+	err := lf.file.Sync()
+	if cerr := lf.file.Close(); err == nil {
+		err = cerr
+	}
+	lf.file = nil
+	return err
+}
+
+// segmentName 返回当天第index个分段的文件名
+func (l *Logger) segmentName(day string, index int) string {
+	if index == 0 {
+		return fmt.Sprintf("%s/%s_%s.log", l.dir, l.obj, day)
+	}
+	return fmt.Sprintf("%s/%s_%s.%d.log", l.dir, l.obj, day, index)
+}
+
+// writeFile 写入日志文件, 日期变化或超过maxSize时切换到新文件.
+// 只返回打开文件的错误; 写入错误(如磁盘满)输出到stderr后继续, 连续失败只报告一次.
+func (l *Logger) writeFile(lf *logFile, data []byte) error {
+	now := time.Now()
+	day := now.Format("2006-01-02")
+	newDay := lf.file == nil || lf.day != day
+	full := l.maxSize > 0 && lf.size > 0 && lf.size+int64(len(data)) > l.maxSize
+	if newDay || full {
+		l.mu.Lock()
+		err := l.openFile(lf, day, newDay)
+		l.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		if l.flag&Ldaily != 0 || l.maxAge > 0 || l.maxBackups > 0 || l.maxTotalSize > 0 {
+			l.rotating.Add(1)
+			go func() {
+				defer l.rotating.Done()
+				l.rotate()
+			}()
+		}
+	}
+	n, err := lf.file.Write(data)
+	lf.size += int64(n)
+	if err != nil && !lf.fail {
+		fmt.Fprintf(os.Stderr, "logd: write log file: %v\n", err)
+	}
+	lf.fail = err != nil
+	return nil
+}
+
+// openFile 打开新的分段, 调用时需持有l.mu.
+// 新的一天从已有的最后一个分段继续写, 同一天内切换到下一个分段.
+func (l *Logger) openFile(lf *logFile, day string, newDay bool) error {
+	if lf.file != nil {
+		lf.file.Close()
+		lf.file = nil
+	}
+	if newDay {
+		lf.day = day
+		lf.index = 0
+		for l.segmentExists(day, lf.index+1) {
+			lf.index++
+		}
+	} else {
+		lf.index++
+	}
+	for {
+		file, err := os.OpenFile(l.segmentName(day, lf.index),
+			os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+		if err != nil {
+			return err
+		}
+		fi, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return err
+		}
+		if l.maxSize > 0 && fi.Size() >= l.maxSize {
+			// 重启后接着写时已写满
+			file.Close()
+			lf.index++
+			continue
+		}
+		lf.file = file
+		lf.size = fi.Size()
+		l.activeFile = file.Name()
+		return nil
+	}
+}
+
+// segmentExists 分段已存在, 包括已压缩的
+func (l *Logger) segmentExists(day string, index int) bool {
+	name := l.segmentName(day, index)
+	if _, err := os.Stat(name); err == nil {
+		return true
+	}
+	_, err := os.Stat(name + ".gz")
+	return err == nil
+}
+
+// rotate 压缩并按保留策略清理本logger的历史日志文件.
+// 只处理匹配 obj_2006-01-02[.n].log[.gz] 的文件, 不处理正在写的文件和当天最新的分段.
+func (l *Logger) rotate() {
+	l.rotateMu.Lock()
+	defer l.rotateMu.Unlock()
+
+	l.mu.Lock()
+	dir, obj := l.dir, l.obj
+	compress := l.flag&Ldaily != 0
+	maxAge, maxBackups, maxTotal := l.maxAge, l.maxBackups, l.maxTotalSize
+	l.mu.Unlock()
+	if compress && maxAge == 0 {
+		maxAge = defaultMaxAge
+	}
+
+	// rotate串行执行, 此时存在的临时文件都是崩溃或出错时遗留的
+	removeStaleTmp(dir, obj)
+	segs, err := listSegments(dir, obj)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "logd: rotate: %v\n", err)
+		return
+	}
+	// 列出文件之后再取正在写的文件: 之后新打开的分段不在segs中, 之前的已经关闭
+	l.mu.Lock()
+	active := filepath.Base(l.activeFile)
+	l.mu.Unlock()
+	now := time.Now()
+	today := now.Format("2006-01-02")
+	newest := ""
+	for _, seg := range segs {
+		if seg.day == today {
+			newest = seg.name
+		}
+	}
+	var total int64
+	backups := 0
+	// 从新到旧
+	for i := len(segs) - 1; i >= 0; i-- {
+		seg := segs[i]
+		path := filepath.Join(dir, seg.name)
+		if seg.name == active || seg.name == newest {
+			total += seg.size
+			continue
+		}
+		backups++
+		if (maxAge > 0 && now.Sub(seg.modTime) > maxAge) ||
+			(maxBackups > 0 && backups > maxBackups) ||
+			(maxTotal > 0 && total+seg.size > maxTotal) {
+			if err := os.Remove(path); err != nil {
+				fmt.Fprintf(os.Stderr, "logd: rotate: %v\n", err)
+			}
+			continue
+		}
+		if compress && !strings.HasSuffix(seg.name, ".gz") {
+			size, err := gzipFile(path, seg.modTime)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "logd: rotate: %v\n", err)
+			} else {
+				seg.size = size
+			}
+		}
+		total += seg.size
+	}
+}
+
+// defaultMaxAge Ldaily且未设置MaxAge时的保留时间
+const defaultMaxAge = 30 * 24 * time.Hour
+
+type segment struct {
+	name    string
+	day     string
+	index   int
+	size    int64
+	modTime time.Time
+}
+
+// listSegments 列出dir中属于obj的日志分段, 按日期和序号从旧到新排序
+func listSegments(dir, obj string) ([]segment, error) {
+	re := regexp.MustCompile(`^` + regexp.QuoteMeta(obj) +
+		`_(\d{4}-\d{2}-\d{2})(?:\.(\d+))?\.log(?:\.gz)?$`)
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var segs []segment
+	for _, fi := range infos {
+		m := re.FindStringSubmatch(fi.Name())
+		if m == nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		index, _ := strconv.Atoi(m[2])
+		segs = append(segs, segment{
+			name:    fi.Name(),
+			day:     m[1],
+			index:   index,
+			size:    fi.Size(),
+			modTime: fi.ModTime(),
+		})
+	}
+	sort.Slice(segs, func(i, j int) bool {
+		if segs[i].day != segs[j].day {
+			return segs[i].day < segs[j].day
+		}
+		return segs[i].index < segs[j].index
+	})
+	return segs, nil
+}
+
+// removeStaleTmp 删除压缩未完成遗留的 obj_2006-01-02[.n].log.gz.tmp
+func removeStaleTmp(dir, obj string) {
+	re := regexp.MustCompile(`^` + regexp.QuoteMeta(obj) + `_\d{4}-\d{2}-\d{2}(?:\.\d+)?\.log\.gz\.tmp$`)
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, fi := range infos {
+		if re.MatchString(fi.Name()) && fi.Mode().IsRegular() {
+			if err := os.Remove(filepath.Join(dir, fi.Name())); err != nil {
+				fmt.Fprintf(os.Stderr, "logd: rotate: %v\n", err)
+			}
+		}
+	}
+}
+
+// gzipFile 压缩为path.gz并删除原文件, 保留修改时间, 返回压缩后大小
+func gzipFile(path string, modTime time.Time) (int64, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+	if err != nil {
+		return 0, err
+	}
+	zw := gzip.NewWriter(dst)
+	zw.Name = filepath.Base(path)
+	zw.ModTime = modTime
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		err = dst.Sync()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	fi, err := os.Stat(tmp)
+	if err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	os.Chtimes(tmp, modTime, modTime)
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	return fi.Size(), os.Remove(path)
+}
@@ -0,0 +1,183 @@
+package logd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSizeRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := New(LogOption{LogDir: dir, MaxSize: 25, Flag: Lall})
+	l.SetObj("app")
+	var lf logFile
+	line := []byte("0123456789\n") // 11 bytes, 两行一个分段
+	for i := 0; i < 5; i++ {
+		if err := l.writeFile(&lf, line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lf.file.Close()
+
+	day := time.Now().Format("2006-01-02")
+	want := map[string]int64{
+		"app_" + day + ".log":   22,
+		"app_" + day + ".1.log": 22,
+		"app_" + day + ".2.log": 11,
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != len(want) {
+		t.Fatalf("got files %v", files)
+	}
+	for name, size := range want {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if err != nil || fi.Size() != size {
+			t.Errorf("%s: %v, size %v, want %d", name, err, fi, size)
+		}
+	}
+
+	// 重启后从最后一个分段继续写
+	lf = logFile{}
+	if err := l.writeFile(&lf, line); err != nil {
+		t.Fatal(err)
+	}
+	lf.file.Close()
+	if !strings.HasSuffix(lf.file.Name(), ".2.log") || lf.size != 22 {
+		t.Errorf("resumed in %s with size %d", lf.file.Name(), lf.size)
+	}
+}
+
+func TestRotateRetention(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := []string{
+		"app_2020-01-01.log",
+		"app_2020-01-02.log",
+		"app_2020-01-02.1.log",
+		"app_2020-01-03.log",
+		"other.log",
+		"app2_2020-01-01.log",
+		"app_2019-12-31.log.gz.tmp",  // 崩溃遗留, 删除
+		"app2_2019-12-31.log.gz.tmp", // 其他obj, 保留
+	}
+	for _, name := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l := New(LogOption{LogDir: dir, MaxBackups: 2, Flag: Lall | Ldaily})
+	l.SetObj("app")
+	l.activeFile = filepath.Join(dir, "app_2020-01-03.log")
+	l.rotate()
+
+	left, _ := filepath.Glob(filepath.Join(dir, "*"))
+	for i := range left {
+		left[i] = filepath.Base(left[i])
+	}
+	want := "app2_2019-12-31.log.gz.tmp app2_2020-01-01.log app_2020-01-02.1.log.gz app_2020-01-02.log.gz app_2020-01-03.log other.log"
+	if got := strings.Join(left, " "); got != want {
+		t.Fatalf("files after rotate:\n got %s\nwant %s", got, want)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "app_2020-01-02.log.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(zr)
+	if string(data) != "app_2020-01-02.log" {
+		t.Errorf("gzip content %q", data)
+	}
+}
+
+// 切分和压缩并发进行时不丢失日志, Close后没有残留的临时文件
+func TestRotate_NoLoss(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := New(LogOption{LogDir: dir, MaxSize: 2000, ChannelLen: 100, Flag: Lerror | Ldaily | LAsync})
+	l.SetObj("app")
+	const n = 20000
+	for i := 0; i < n; i++ {
+		l.Error("0123456789abcdef\n")
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	lines := 0
+	for _, name := range files {
+		if strings.HasSuffix(name, ".tmp") {
+			t.Fatalf("temporary file left: %s", name)
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r io.Reader = f
+		if strings.HasSuffix(name, ".gz") {
+			if r, err = gzip.NewReader(f); err != nil {
+				t.Fatal(err)
+			}
+		}
+		data, err := ioutil.ReadAll(r)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines += strings.Count(string(data), "\n")
+	}
+	if lines != n {
+		t.Fatalf("got %d lines in %d files, want %d", lines, len(files), n)
+	}
+}
+
+// 写日志文件失败(磁盘满)时不panic, 其他输出继续
+func TestWriteFile_Error(t *testing.T) {
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("no /dev/full")
+	}
+	dir, err := ioutil.TempDir("", "logd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	day := time.Now().Format("2006-01-02")
+	if err := os.Symlink("/dev/full", filepath.Join(dir, "app_"+day+".log")); err != nil {
+		t.Skip(err)
+	}
+
+	var buf bytes.Buffer
+	l := New(LogOption{Out: &buf, LogDir: dir, Flag: Lerror | LAsync})
+	l.SetObj("app")
+	for i := 0; i < 3; i++ {
+		l.Error("disk full\n")
+	}
+	l.Close()
+	if n := strings.Count(buf.String(), "\n"); n != 3 {
+		t.Fatalf("out got %d lines, want 3", n)
+	}
+}
@@ -0,0 +1,116 @@
+package logd
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Sampling 按调用位置和级别采样: 每个Interval内前First条全部输出,
+// 之后每Thereafter条输出一条, Thereafter为0时全部丢弃.
+// 每个Interval结束时为被丢弃的调用位置输出一条 "suppressed N similar messages".
+type Sampling struct {
+	Interval   time.Duration
+	First      int
+	Thereafter int
+}
+
+type sampleKey struct {
+	lvl  int
+	file string
+	line int
+}
+
+type sampleCount struct {
+	n          int // 本周期内的日志数
+	suppressed int // 本周期内丢弃的日志数
+}
+
+type sampler struct {
+	mu     sync.Mutex
+	l      *Logger
+	opt    Sampling
+	counts map[sampleKey]*sampleCount
+	quit   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newSampler(l *Logger, opt Sampling) *sampler {
+	if opt.Interval <= 0 {
+		opt.Interval = time.Second
+	}
+	s := &sampler{
+		l:      l,
+		opt:    opt,
+		counts: make(map[sampleKey]*sampleCount),
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// allow 本条日志是否输出
+func (s *sampler) allow(lvl int, file string, line int) bool {
+	key := sampleKey{lvl: lvl, file: file, line: line}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.counts[key]
+	if c == nil {
+		c = &sampleCount{}
+		s.counts[key] = c
+	}
+	c.n++
+	if c.n <= s.opt.First {
+		return true
+	}
+	if s.opt.Thereafter > 0 && (c.n-s.opt.First)%s.opt.Thereafter == 0 {
+		return true
+	}
+	c.suppressed++
+	return false
+}
+
+func (s *sampler) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.opt.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.summarize()
+		case <-s.quit:
+			s.summarize()
+			return
+		}
+	}
+}
+
+// summarize 开始新周期, 为有丢弃的调用位置输出汇总
+func (s *sampler) summarize() {
+	s.mu.Lock()
+	counts := s.counts
+	s.counts = make(map[sampleKey]*sampleCount, len(counts))
+	s.mu.Unlock()
+
+	for key, c := range counts {
+		if c.suppressed == 0 {
+			continue
+		}
+		s.l.write(&Record{
+			Time:    time.Now(),
+			Level:   key.lvl,
+			Obj:     s.l.getObj(),
+			File:    key.file,
+			Line:    key.line,
+			Message: fmt.Sprintf("suppressed %d similar messages\n", c.suppressed),
+		})
+	}
+}
+
+// stop 输出最后一个周期的汇总并停止
+func (s *sampler) stop() {
+	s.once.Do(func() { close(s.quit) })
+	<-s.done
+}
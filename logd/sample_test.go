@@ -0,0 +1,34 @@
+package logd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSampling(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(LogOption{
+		Out:      &buf,
+		Flag:     Lall,
+		Format:   FormatLogfmt,
+		Sampling: &Sampling{Interval: time.Hour, First: 2, Thereafter: 5},
+	})
+	for i := 0; i < 20; i++ {
+		l.Error("boom")
+		l.Info("other site")
+	}
+	l.Close()
+
+	out := buf.String()
+	if n := strings.Count(out, "msg=boom"); n != 5 {
+		t.Errorf("logged %d of 20 boom lines, want 5", n)
+	}
+	if n := strings.Count(out, `msg="other site"`); n != 5 {
+		t.Errorf("logged %d of 20 other lines, want 5", n)
+	}
+	if n := strings.Count(out, `msg="suppressed 15 similar messages"`); n != 2 {
+		t.Errorf("got %d summary lines, want 2:\n%s", n, out)
+	}
+}
@@ -0,0 +1,51 @@
+//go:build !windows
+// +build !windows
+
+package logd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// HandleSignals SIGUSR1切换到debug级别, SIGUSR2恢复调用时的级别,
+// SIGHUP重新打开日志文件(配合logrotate), 返回的函数停止处理信号
+func (l *Logger) HandleSignals() (stop func()) {
+	l = l.root()
+	configured := l.Level()
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGHUP)
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case sig := <-ch:
+				switch sig {
+				case syscall.SIGUSR1:
+					l.SetLevel(Ldebug)
+				case syscall.SIGUSR2:
+					l.SetLevel(configured)
+				case syscall.SIGHUP:
+					if err := l.Reopen(); err != nil {
+						fmt.Fprintln(os.Stderr, "logd: reopen:", err)
+					}
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(quit)
+		<-done
+	}
+}
+
+func HandleSignals() (stop func()) {
+	return Std.HandleSignals()
+}
@@ -0,0 +1,63 @@
+//go:build !windows
+// +build !windows
+
+package logd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestHandleSignals(t *testing.T) {
+	l := New(LogOption{Out: ioutil.Discard, Flag: Lerror | Lfatal})
+	stop := l.HandleSignals()
+	defer stop()
+
+	wait := func(lvl int) {
+		for i := 0; i < 100 && l.Level() != lvl; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if got := l.Level(); got != lvl {
+			t.Fatalf("level = %s, want %s", LevelName(got), LevelName(lvl))
+		}
+	}
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	wait(Ldebug)
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR2)
+	wait(Lerror)
+}
+
+func TestHandleSignals_Reopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	l := New(LogOption{Out: f, Flag: Lerror})
+	stop := l.HandleSignals()
+	defer stop()
+
+	os.Rename(path, path+".1")
+	syscall.Kill(syscall.Getpid(), syscall.SIGHUP)
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	l.Error("after\n")
+	data, err := ioutil.ReadFile(path)
+	if err != nil || string(data) != "[ERROR] after\n" {
+		t.Fatalf("reopened file = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,10 @@
+package logd
+
+// HandleSignals windows没有SIGUSR1/SIGUSR2/SIGHUP, 不做任何处理, 需要时直接调用Reopen
+func (l *Logger) HandleSignals() (stop func()) {
+	return func() {}
+}
+
+func HandleSignals() (stop func()) {
+	return Std.HandleSignals()
+}
@@ -0,0 +1,92 @@
+package logd
+
+import (
+	"io"
+)
+
+// Sink 日志输出目标, 与Out和LogDir同时输出.
+// Sink的级别在logger的级别之上再过滤, 实现了Sync() error的Sink在Flush时调用,
+// 实现了io.Closer的Sink在Close时关闭.
+type Sink interface {
+	Enabled(lvl int) bool
+	WriteRecord(r *Record) error
+}
+
+// WriterSink 按自己的最低级别和格式写到io.Writer
+type WriterSink struct {
+	Out       io.Writer
+	Level     int       // 最低级别, 如Lerror只输出error和fatal, 0输出全部
+	Formatter Formatter // 为空时使用文本格式, 按Out是否为终端决定颜色
+}
+
+func (s *WriterSink) Enabled(lvl int) bool {
+	return lvl >= s.Level
+}
+
+func (s *WriterSink) WriteRecord(r *Record) error {
+	_, err := s.Out.Write(s.Formatter.Format(r))
+	return err
+}
+
+func (s *WriterSink) Sync() error {
+	syncWriter(s.Out)
+	return nil
+}
+
+// AddSink 增加一个输出目标
+func (l *Logger) AddSink(sink Sink) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if ws, ok := sink.(*WriterSink); ok && ws.Formatter == nil {
+		c := *ws
+		tf := &TextFormatter{Flag: l.flag, Theme: l.theme}
+		switch l.color {
+		case ColorAlways:
+			tf.Color = true
+		case ColorAuto:
+			tf.Color = isTerminal(c.Out) && !noColor()
+		}
+		c.Formatter = tf
+		sink = &c
+	}
+	// 写时复制, receive读取时不用一直持有锁
+	sinks := make([]Sink, 0, len(l.sinks)+1)
+	sinks = append(sinks, l.sinks...)
+	l.sinks = append(sinks, sink)
+}
+
+// writeSinks 写入所有启用了该级别的Sink, 返回第一个错误
+func writeSinks(sinks []Sink, r *Record) error {
+	var first error
+	for _, s := range sinks {
+		if !s.Enabled(r.Level) {
+			continue
+		}
+		if err := s.WriteRecord(r); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func syncSinks(sinks []Sink) {
+	for _, s := range sinks {
+		if syncer, ok := s.(interface{ Sync() error }); ok {
+			syncer.Sync()
+		}
+	}
+}
+
+// closeSinks 关闭实现了io.Closer的Sink并清空, 调用时需持有l.mu
+func (l *Logger) closeSinks() error {
+	var first error
+	for _, s := range l.sinks {
+		if c, ok := s.(io.Closer); ok {
+			if err := c.Close(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	l.sinks = nil
+	return first
+}
@@ -0,0 +1,48 @@
+package logd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type closeSink struct {
+	WriterSink
+	closed bool
+}
+
+func (s *closeSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestSinks(t *testing.T) {
+	for _, flag := range []int{Lall, Lall | LAsync} {
+		var text, js, errs bytes.Buffer
+		cs := &closeSink{WriterSink: WriterSink{Out: &errs, Level: Lerror, Formatter: &LogfmtFormatter{}}}
+		l := New(LogOption{
+			Out:  &text,
+			Flag: flag,
+			Sinks: []Sink{
+				&WriterSink{Out: &js, Formatter: &JSONFormatter{}},
+				cs,
+			},
+		})
+		l.Info("hello")
+		l.Error("boom")
+		l.Close()
+
+		if got := text.String(); got != "[ INFO] hello[ERROR] boom" {
+			t.Errorf("flag %d: text output %q", flag, got)
+		}
+		if n := strings.Count(js.String(), `"msg":`); n != 2 {
+			t.Errorf("flag %d: json sink got %d records: %s", flag, n, js.String())
+		}
+		if got := errs.String(); strings.Contains(got, "hello") || !strings.Contains(got, "msg=boom") {
+			t.Errorf("flag %d: error sink got %q", flag, got)
+		}
+		if !cs.closed {
+			t.Errorf("flag %d: sink not closed", flag)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+//go:build go1.21
+// +build go1.21
+
+package logd
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// SlogHandler 将log/slog的记录交给logd输出, 经过logd的级别、obj、输出、异步channel和邮件告警.
+// 分组以 "." 连接到key上, 如 slog.Group("req", "id", 1) 输出为 req.id=1
+type SlogHandler struct {
+	l      *Logger
+	prefix string  // 当前分组前缀, 如 "req."
+	fields []Field // WithAttrs添加的字段
+}
+
+// NewSlogHandler 返回输出到l的slog.Handler, 如 slog.New(logd.NewSlogHandler(logd.Std))
+func NewSlogHandler(l *Logger) *SlogHandler {
+	return &SlogHandler{l: l}
+}
+
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.l.enabled(levelFromSlog(level))
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	l := h.l.Ctx(ctx)
+	lvl := levelFromSlog(r.Level)
+	var file string
+	var line int
+	if r.PC != 0 {
+		f, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		file, line = f.File, f.Line
+	}
+	if l.sampler != nil && !l.sampler.allow(lvl, file, line) {
+		return nil
+	}
+
+	fields := make([]Field, 0, len(l.fields)+len(h.fields)+r.NumAttrs())
+	fields = append(fields, l.fields...)
+	fields = append(fields, h.fields...)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendSlogAttr(fields, h.prefix, a)
+		return true
+	})
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return l.write(&Record{
+		Time:    t,
+		Level:   lvl,
+		Obj:     l.getObj(),
+		File:    file,
+		Line:    line,
+		Message: r.Message + "\n",
+		Fields:  fields,
+		Name:    l.Name(),
+		Stack:   l.captureStack(lvl, 1),
+	})
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	c := *h
+	c.fields = make([]Field, 0, len(h.fields)+len(attrs))
+	c.fields = append(c.fields, h.fields...)
+	for _, a := range attrs {
+		c.fields = appendSlogAttr(c.fields, h.prefix, a)
+	}
+	return &c
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := *h
+	c.prefix = h.prefix + name + "."
+	return &c
+}
+
+// appendSlogAttr 展开分组, 忽略空属性, key为空的分组内联到上级
+func appendSlogAttr(fields []Field, prefix string, a slog.Attr) []Field {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		attrs := v.Group()
+		if len(attrs) == 0 {
+			return fields
+		}
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range attrs {
+			fields = appendSlogAttr(fields, prefix, ga)
+		}
+		return fields
+	}
+	if a.Key == "" && v.Any() == nil {
+		return fields
+	}
+	return append(fields, Field{Key: prefix + a.Key, Value: v.Any()})
+}
+
+// levelFromSlog Debug以下为debug, Info为info, Warn为warn, Error及以上为error
+func levelFromSlog(level slog.Level) int {
+	switch {
+	case level < slog.LevelInfo:
+		return Ldebug
+	case level < slog.LevelWarn:
+		return Linfo
+	case level < slog.LevelError:
+		return Lwarn
+	default:
+		return Lerror
+	}
+}
+
+// levelToSlog fatal对应 slog.LevelError+4
+func levelToSlog(lvl int) slog.Level {
+	switch {
+	case lvl >= Lfatal:
+		return slog.LevelError + 4
+	case lvl >= Lerror:
+		return slog.LevelError
+	case lvl >= Lwarn:
+		return slog.LevelWarn
+	case lvl >= Linfo:
+		return slog.LevelInfo
+	default:
+		return slog.LevelDebug
+	}
+}
+
+// SlogSink 将logd的记录转发给任意slog.Handler, obj和模块名作为 obj, logger 属性
+type SlogSink struct {
+	Handler slog.Handler
+	Level   int  // 最低级别, 0输出全部
+	Caller  bool // 增加 caller=file:line 属性
+}
+
+func (s *SlogSink) Enabled(lvl int) bool {
+	return lvl >= s.Level && s.Handler.Enabled(context.Background(), levelToSlog(lvl))
+}
+
+func (s *SlogSink) WriteRecord(r *Record) error {
+	msg := r.Message
+	if n := len(msg); n > 0 && msg[n-1] == '\n' {
+		msg = msg[:n-1]
+	}
+	sr := slog.NewRecord(r.Time, levelToSlog(r.Level), msg, 0)
+	if r.Obj != "" {
+		sr.AddAttrs(slog.String("obj", r.Obj))
+	}
+	if r.Name != "" {
+		sr.AddAttrs(slog.String("logger", r.Name))
+	}
+	if s.Caller && r.File != "" {
+		sr.AddAttrs(slog.String("caller", shortFile(r.File)+":"+strconv.Itoa(r.Line)))
+	}
+	for _, f := range r.Fields {
+		sr.AddAttrs(slog.Any(f.Key, f.Value))
+	}
+	if len(r.Stack) > 0 {
+		frames := make([]string, len(r.Stack))
+		for i, f := range r.Stack {
+			frames[i] = f.Function + " " + f.File + ":" + strconv.Itoa(f.Line)
+		}
+		sr.AddAttrs(slog.Any("stack", frames))
+	}
+	return s.Handler.Handle(context.Background(), sr)
+}
+
+// NewSlogLogger 返回只输出到h的logger, flag控制级别, 如 Ldebug|Linfo|Lwarn|Lerror|Lfatal
+func NewSlogLogger(h slog.Handler, flag int) *Logger {
+	return New(LogOption{Flag: flag, Sinks: []Sink{&SlogSink{Handler: h}}})
+}
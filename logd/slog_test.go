@@ -0,0 +1,47 @@
+//go:build go1.21
+// +build go1.21
+
+package logd
+
+import (
+	"bytes"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestSlogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(LogOption{Out: &buf, Flag: Linfo | Lwarn | Lerror | Lfatal | Lshortfile})
+	logger := slog.New(NewSlogHandler(l.Named("db"))).WithGroup("req").With("id", 7)
+
+	logger.Debug("hidden")
+	_, _, line, _ := runtime.Caller(0)
+	logger.Info("hi", "k", "v", slog.Group("g", "a", 1), slog.Group("empty"))
+	want := "[ INFO] [db] slog_test.go:" + strconv.Itoa(line+1) + ": hi req.id=7 req.k=v req.g.a=1\n"
+	if buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestSlogSink(t *testing.T) {
+	var buf bytes.Buffer
+	h := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	l := NewSlogLogger(h, Lwarn|Lerror|Lfatal)
+	l.SetObj("api")
+	l.Named("db").With("k", "v").Warn("hello\n")
+	l.Info("filtered\n")
+	want := "level=WARN msg=hello obj=api logger=db k=v\n"
+	if buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,150 @@
+package logd
+
+import (
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const defaultStackDepth = 32
+
+// Frame 调用栈中的一帧
+type Frame struct {
+	Function string // 完整函数名, 如 github.com/yahao333/utils/logd.(*Logger).Output
+	File     string
+	Line     int
+}
+
+// Stack 调用栈, 最近的调用在前, 不含runtime和标准库的帧
+type Stack []Frame
+
+// CaptureStack 获取调用栈, skip为0从调用CaptureStack的函数开始.
+// 最多保留depth帧(<=0时为32), module不为空时只保留该模块路径下和main包的帧.
+func CaptureStack(skip, depth int, module string) Stack {
+	if depth <= 0 {
+		depth = defaultStackDepth
+	}
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var stack Stack
+	for len(stack) < depth {
+		f, more := frames.Next()
+		if f.Function != "" && !isStdFrame(f) && inModule(f.Function, module) {
+			stack = append(stack, Frame{Function: f.Function, File: f.File, Line: f.Line})
+		}
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
+var goroot = filepath.ToSlash(runtime.GOROOT())
+
+// isStdFrame runtime和标准库的帧: 文件在GOROOT/src下;
+// -trimpath编译或GOROOT未知时按包路径判断, 首段不含 "." 的为标准库
+func isStdFrame(f runtime.Frame) bool {
+	if goroot != "" && filepath.IsAbs(f.File) {
+		return strings.HasPrefix(f.File, goroot+"/src/")
+	}
+	fn := f.Function
+	if strings.HasPrefix(fn, "main.") {
+		return false
+	}
+	if i := strings.IndexByte(fn, '/'); i >= 0 {
+		return !strings.Contains(fn[:i], ".")
+	}
+	return true
+}
+
+// inModule 函数是否属于module或main包, module为空时都属于
+func inModule(fn, module string) bool {
+	if module == "" || strings.HasPrefix(fn, "main.") {
+		return true
+	}
+	return strings.HasPrefix(fn, module+"/") || strings.HasPrefix(fn, module+".")
+}
+
+// String 与panic输出相同的格式, 每帧两行:
+//
+//	main.handler
+//		/src/app/main.go:42
+func (s Stack) String() string {
+	var buf []byte
+	s.appendText(&buf)
+	return string(buf)
+}
+
+func (s Stack) appendText(buf *[]byte) {
+	for _, f := range s {
+		*buf = append(*buf, '\t')
+		*buf = append(*buf, f.Function...)
+		*buf = append(*buf, "\n\t\t"...)
+		*buf = append(*buf, f.File...)
+		*buf = append(*buf, ':')
+		*buf = strconv.AppendInt(*buf, int64(f.Line), 10)
+		*buf = append(*buf, '\n')
+	}
+}
+
+// oneLine 单行格式 "main.handler main.go:42, main.main main.go:10", 用于logfmt和syslog
+func (s Stack) oneLine(short bool) string {
+	var buf []byte
+	for i, f := range s {
+		if i > 0 {
+			buf = append(buf, ", "...)
+		}
+		buf = append(buf, f.Function...)
+		buf = append(buf, ' ')
+		if short {
+			buf = append(buf, shortFile(f.File)...)
+		} else {
+			buf = append(buf, f.File...)
+		}
+		buf = append(buf, ':')
+		buf = strconv.AppendInt(buf, int64(f.Line), 10)
+	}
+	return string(buf)
+}
+
+// appendJSONStack [{"func":"...","file":"...","line":42},...]
+func appendJSONStack(buf *[]byte, s Stack, short bool) {
+	*buf = append(*buf, '[')
+	for i, f := range s {
+		if i > 0 {
+			*buf = append(*buf, ',')
+		}
+		*buf = append(*buf, `{"func":`...)
+		appendJSONString(buf, f.Function)
+		*buf = append(*buf, `,"file":`...)
+		if short {
+			appendJSONString(buf, shortFile(f.File))
+		} else {
+			appendJSONString(buf, f.File)
+		}
+		*buf = append(*buf, `,"line":`...)
+		*buf = strconv.AppendInt(*buf, int64(f.Line), 10)
+		*buf = append(*buf, '}')
+	}
+	*buf = append(*buf, ']')
+}
+
+// SetStack 设置附带调用栈的级别, 如 Lerror|Lfatal, 0不附带
+func (l *Logger) SetStack(levels int) {
+	atomic.StoreInt32(&l.stackLevels, int32(levels&Lall))
+}
+
+// captureStack lvl需要附带调用栈时获取, skip为0从调用captureStack的函数开始
+func (l *Logger) captureStack(lvl int, skip int) Stack {
+	if int(atomic.LoadInt32(&l.stackLevels))&lvl == 0 {
+		return nil
+	}
+	return CaptureStack(skip+1, l.stackDepth, l.stackModule)
+}
+
+func SetStack(levels int) {
+	Std.SetStack(levels)
+}
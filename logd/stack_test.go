@@ -0,0 +1,102 @@
+package logd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func stackHelper(l *Logger) {
+	l.Error("boom\n")
+}
+
+func TestStack(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(LogOption{Out: &buf, Flag: Lall, StackLevels: Lerror})
+	l.Warn("no stack\n")
+	if buf.String() != "[ WARN] no stack\n" {
+		t.Fatalf("warn = %q", buf.String())
+	}
+
+	buf.Reset()
+	stackHelper(l)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if lines[0] != "[ERROR] boom" || len(lines) < 5 || len(lines)%2 != 1 {
+		t.Fatalf("error = %q", buf.String())
+	}
+	if !strings.HasSuffix(lines[1], "logd.stackHelper") || !strings.Contains(lines[2], "stack_test.go:12") ||
+		!strings.HasSuffix(lines[3], "logd.TestStack") {
+		t.Fatalf("stack = %q", buf.String())
+	}
+	for _, line := range lines[1:] {
+		if strings.Contains(line, "runtime.") || strings.Contains(line, "testing.") {
+			t.Fatalf("stdlib frame in stack: %q", line)
+		}
+	}
+}
+
+func TestStack_Options(t *testing.T) {
+	s := CaptureStack(0, 1, "")
+	if len(s) != 1 || !strings.HasSuffix(s[0].Function, "logd.TestStack_Options") {
+		t.Fatalf("depth 1 = %v", s)
+	}
+	if s := CaptureStack(0, 0, "example.com/other"); len(s) != 0 {
+		t.Fatalf("module filter = %v", s)
+	}
+	if s := CaptureStack(0, 0, "github.com/yahao333/utils"); len(s) != 1 {
+		t.Fatalf("own module = %v", s)
+	}
+}
+
+func TestStack_Formats(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(LogOption{Out: &buf, Flag: Lall | Lshortfile, Format: FormatJSON, StackLevels: Lerror | Lfatal})
+	stackHelper(l)
+	var rec struct {
+		Stack []struct {
+			Func string `json:"func"`
+			File string `json:"file"`
+			Line int    `json:"line"`
+		} `json:"stack"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("%v: %s", err, buf.String())
+	}
+	if len(rec.Stack) < 2 || rec.Stack[0].File != "stack_test.go" || rec.Stack[0].Line != 12 {
+		t.Fatalf("json stack = %+v", rec.Stack)
+	}
+
+	buf.Reset()
+	l.SetFormatter(&LogfmtFormatter{Flag: Lshortfile})
+	l.SetStack(Lfatal)
+	stackHelper(l)
+	if strings.Contains(buf.String(), "stack=") {
+		t.Fatalf("stack after SetStack(Lfatal): %q", buf.String())
+	}
+	buf.Reset()
+	l.SetStack(Lerror)
+	stackHelper(l)
+	if !strings.Contains(buf.String(), ` stack="github.com/yahao333/utils/logd.stackHelper stack_test.go:12, `) {
+		t.Fatalf("logfmt = %q", buf.String())
+	}
+}
+
+// 异步输出与SetStack并发, 用 -race 检查
+func TestSetStack_Concurrent(t *testing.T) {
+	l := New(LogOption{Out: ioutil.Discard, Flag: Lall | LAsync})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			l.Error("x\n")
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		l.SetStack(Lerror)
+		l.SetStack(0)
+	}
+	<-done
+	l.Close()
+}
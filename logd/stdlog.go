@@ -0,0 +1,110 @@
+package logd
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// lineWriter 按行切分写入的数据, 每行以级别lvl输出一条日志, 不完整的行缓存到下次写入
+type lineWriter struct {
+	l   *Logger
+	lvl int
+	mu  sync.Mutex
+	buf []byte
+}
+
+// Writer 返回一个io.Writer, 写入的每一行以级别lvl输出, 用于接入只接受io.Writer的第三方库.
+// 调用位置为第一个不属于log, fmt, io, bufio包的调用者.
+func (l *Logger) Writer(lvl int) io.Writer {
+	return &lineWriter{l: l, lvl: lvl}
+}
+
+// StdLogger 返回输出到l的标准库*log.Logger, 每行以级别lvl输出
+func (l *Logger) StdLogger(lvl int) *log.Logger {
+	return log.New(l.Writer(lvl), "", 0)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	file, line := bridgeCaller()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.emit(file, line, string(w.buf[:i+1]))
+		w.buf = w.buf[i+1:]
+	}
+	if len(w.buf) == 0 {
+		w.buf = nil
+	}
+	return len(p), nil
+}
+
+// Sync 输出缓存中不完整的行
+func (w *lineWriter) Sync() error {
+	file, line := bridgeCaller()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		w.emit(file, line, string(w.buf)+"\n")
+		w.buf = nil
+	}
+	return nil
+}
+
+func (w *lineWriter) emit(file string, line int, content string) {
+	if w.l.enabled(w.lvl) {
+		w.l.outputAt(w.lvl, 3, file, line, content)
+	}
+}
+
+// bridgeCaller 跳过lineWriter和标准库的输出函数, 返回实际调用位置
+func bridgeCaller() (string, int) {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	first, more := frames.Next()
+	for f := first; ; f, more = frames.Next() {
+		if !isBridgeFrame(f.Function) {
+			return f.File, f.Line
+		}
+		if !more {
+			break
+		}
+	}
+	return first.File, first.Line
+}
+
+func isBridgeFrame(fn string) bool {
+	for _, pkg := range []string{"log.", "fmt.", "io.", "bufio."} {
+		if strings.HasPrefix(fn, pkg) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedirectStdLog 将标准库log包的输出重定向到l, 每行以级别lvl输出,
+// 返回的函数恢复log包原来的输出、前缀和flag
+func (l *Logger) RedirectStdLog(lvl int) (restore func()) {
+	flags, prefix, out := log.Flags(), log.Prefix(), log.Writer()
+	log.SetFlags(0)
+	log.SetPrefix("")
+	log.SetOutput(l.Writer(lvl))
+	return func() {
+		log.SetFlags(flags)
+		log.SetPrefix(prefix)
+		log.SetOutput(out)
+	}
+}
+
+func RedirectStdLog(lvl int) (restore func()) {
+	return Std.RedirectStdLog(lvl)
+}
@@ -0,0 +1,51 @@
+package logd
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestRedirectStdLog(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(LogOption{Out: &buf, Flag: Lwarn | Lerror | Lshortfile})
+	out, flags := log.Writer(), log.Flags()
+	restore := l.RedirectStdLog(Lwarn)
+	_, _, line, _ := runtime.Caller(0)
+	log.Printf("first\nsecond")
+	restore()
+	if log.Writer() != out || log.Flags() != flags {
+		t.Fatal("log package not restored")
+	}
+
+	caller := "stdlog_test.go:" + strconv.Itoa(line+1) + ": "
+	want := "[ WARN] " + caller + "first\n[ WARN] " + caller + "second\n"
+	if buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(LogOption{Out: &buf, Flag: Lerror | Lshortfile})
+	w := l.Writer(Lerror)
+	_, _, line, _ := runtime.Caller(0)
+	fmt.Fprint(w, "par")
+	fmt.Fprint(w, "tial\nrest")
+	w.(interface{ Sync() error }).Sync()
+
+	caller := "stdlog_test.go:" + strconv.Itoa(line+2) + ": "
+	want := "[ERROR] " + caller + "partial\n[ERROR] stdlog_test.go:" + strconv.Itoa(line+3) + ": rest\n"
+	if buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	l.StdLogger(Linfo).Print("filtered")
+	if buf.Len() != 0 {
+		t.Fatalf("info written: %q", buf.String())
+	}
+}
@@ -0,0 +1,238 @@
+package logd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// syslog facility
+const (
+	FacilityKern   = 0
+	FacilityUser   = 1
+	FacilityMail   = 2
+	FacilityDaemon = 3
+	FacilityAuth   = 4
+	FacilitySyslog = 5
+	FacilityLocal0 = 16
+	FacilityLocal1 = 17
+	FacilityLocal2 = 18
+	FacilityLocal3 = 19
+	FacilityLocal4 = 20
+	FacilityLocal5 = 21
+	FacilityLocal6 = 22
+	FacilityLocal7 = 23
+)
+
+// 日志级别到syslog severity的映射
+var syslogSeverity = map[int]int{
+	Ldebug: 7, // debug
+	Linfo:  6, // informational
+	Lwarn:  4, // warning
+	Lerror: 3, // error
+	Lfatal: 2, // critical
+}
+
+// syslogSDID 结构化数据的SD-ID, 32473为RFC 5424中的示例企业号
+const syslogSDID = "fields@32473"
+
+// SyslogSink 以RFC 5424(或RFC 3164)格式发送到syslog.
+// udp和unixgram每条日志一个数据报, tcp和unix使用octet counting分帧(RFC 6587).
+type SyslogSink struct {
+	Network  string // udp, tcp, unix, unixgram, 为空时连接本机的/dev/log
+	Addr     string
+	Facility int    // 为0时使用FacilityUser
+	AppName  string // 为空使用日志对象obj
+	Hostname string // 为空使用os.Hostname
+	Level    int    // 最低级别, 0输出全部
+	RFC3164  bool   // 使用BSD格式: <PRI>Mmm dd hh:mm:ss HOST TAG[PID]: MSG
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogSink 创建并连接syslog
+func NewSyslogSink(network, addr string, facility int) (*SyslogSink, error) {
+	s := &SyslogSink{Network: network, Addr: addr, Facility: facility}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// connect 调用时需持有s.mu
+func (s *SyslogSink) connect() error {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	if s.Network != "" {
+		conn, err := net.Dial(s.Network, s.Addr)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+		return nil
+	}
+	for _, path := range []string{"/dev/log", "/var/run/syslog", "/var/run/log"} {
+		if conn, err := net.Dial("unixgram", path); err == nil {
+			s.Network, s.Addr, s.conn = "unixgram", path, conn
+			return nil
+		}
+	}
+	return errors.New("logd: no local syslog socket")
+}
+
+func (s *SyslogSink) stream() bool {
+	return s.Network == "tcp" || s.Network == "tcp4" || s.Network == "tcp6" || s.Network == "unix"
+}
+
+func (s *SyslogSink) Enabled(lvl int) bool {
+	return lvl >= s.Level
+}
+
+// WriteRecord 发送一条日志, 流式连接写失败时重连一次
+func (s *SyslogSink) WriteRecord(r *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Hostname == "" {
+		s.Hostname, _ = os.Hostname()
+	}
+	msg := s.format(r)
+	if s.stream() {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	if s.conn != nil {
+		if _, err := s.conn.Write(msg); err == nil {
+			return nil
+		}
+	}
+	if err := s.connect(); err != nil {
+		return err
+	}
+	_, err := s.conn.Write(msg)
+	return err
+}
+
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+func (s *SyslogSink) format(r *Record) []byte {
+	facility := s.Facility
+	if facility == 0 {
+		facility = FacilityUser
+	}
+	severity, ok := syslogSeverity[r.Level]
+	if !ok {
+		severity = 6
+	}
+	app := s.AppName
+	if app == "" {
+		app = r.Obj
+	}
+	msg := strings.TrimRight(r.Message, "\n")
+
+	buf := make([]byte, 0, 128+len(msg))
+	buf = append(buf, '<')
+	buf = strconv.AppendInt(buf, int64(facility*8+severity), 10)
+	buf = append(buf, '>')
+	if s.RFC3164 {
+		buf = r.Time.AppendFormat(buf, time.Stamp)
+		buf = append(buf, ' ')
+		buf = appendSyslogHeader(buf, s.Hostname, 255)
+		buf = append(buf, ' ')
+		buf = appendSyslogHeader(buf, app, 32)
+		buf = append(buf, '[')
+		buf = strconv.AppendInt(buf, int64(os.Getpid()), 10)
+		buf = append(buf, "]: "...)
+		buf = append(buf, shortFile(r.File)...)
+		buf = append(buf, ':')
+		buf = strconv.AppendInt(buf, int64(r.Line), 10)
+		buf = append(buf, ": "...)
+		buf = append(buf, msg...)
+		return buf
+	}
+
+	// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG
+	buf = append(buf, "1 "...)
+	buf = r.Time.AppendFormat(buf, "2006-01-02T15:04:05.000000Z07:00")
+	buf = append(buf, ' ')
+	buf = appendSyslogHeader(buf, s.Hostname, 255)
+	buf = append(buf, ' ')
+	buf = appendSyslogHeader(buf, app, 48)
+	buf = append(buf, ' ')
+	buf = strconv.AppendInt(buf, int64(os.Getpid()), 10)
+	buf = append(buf, " - ["+syslogSDID...)
+	buf = appendSyslogParam(buf, "caller", shortFile(r.File)+":"+strconv.Itoa(r.Line))
+	if r.Name != "" {
+		buf = appendSyslogParam(buf, "logger", r.Name)
+	}
+	for _, f := range r.Fields {
+		buf = appendSyslogParam(buf, f.Key, fmt.Sprint(f.Value))
+	}
+	if len(r.Stack) > 0 {
+		buf = appendSyslogParam(buf, "stack", r.Stack.oneLine(true))
+	}
+	buf = append(buf, "] "...)
+	return append(buf, msg...)
+}
+
+// appendSyslogHeader 头部字段只能是可打印ASCII, 不含空格, 为空时为 "-"
+func appendSyslogHeader(buf []byte, s string, max int) []byte {
+	if s == "" {
+		return append(buf, '-')
+	}
+	if len(s) > max {
+		s = s[:max]
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c > '~' {
+			c = '_'
+		}
+		buf = append(buf, c)
+	}
+	return buf
+}
+
+// appendSyslogParam 追加 name="value", name最长32且不含 = ] " 和空格, value转义 " \ ]
+func appendSyslogParam(buf []byte, name, value string) []byte {
+	buf = append(buf, ' ')
+	if name == "" {
+		name = "_"
+	}
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c <= ' ' || c > '~' || c == '=' || c == ']' || c == '"' {
+			c = '_'
+		}
+		buf = append(buf, c)
+	}
+	buf = append(buf, '=', '"')
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c == '"' || c == '\\' || c == ']' {
+			buf = append(buf, '\\')
+		}
+		buf = append(buf, c)
+	}
+	return append(buf, '"')
+}
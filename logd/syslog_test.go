@@ -0,0 +1,91 @@
+package logd
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSyslogUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	sink, err := NewSyslogSink("udp", pc.LocalAddr().String(), FacilityLocal0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.Hostname = "host1"
+	l := New(LogOption{Flag: Lall, Sinks: []Sink{sink}})
+	l.SetObj("api")
+	l.With("user", `a"b]`).Warn("disk full\n")
+	l.Close()
+
+	buf := make([]byte, 1024)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// local0(16)*8 + warning(4) = 132
+	re := regexp.MustCompile(`^<132>1 \S+ host1 api \d+ - \[fields@32473 caller="syslog_test\.go:\d+" user="a\\"b\\]"\] disk full$`)
+	if got := string(buf[:n]); !re.MatchString(got) {
+		t.Errorf("unexpected message %q", got)
+	}
+}
+
+func TestSyslogTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	msgs := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			// octet counting: LEN SP MSG
+			size, err := r.ReadString(' ')
+			if err != nil {
+				close(msgs)
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(size))
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(r, msg); err != nil {
+				return
+			}
+			msgs <- string(msg)
+		}
+	}()
+
+	sink := &SyslogSink{Network: "tcp", Addr: ln.Addr().String(), AppName: "app", RFC3164: true}
+	l := New(LogOption{Flag: Lall, Sinks: []Sink{sink}})
+	l.Error("first")
+	l.Debug("second")
+	l.Close()
+
+	re := regexp.MustCompile(`^<(\d+)>\w{3} [ \d]\d \d\d:\d\d:\d\d \S+ app\[\d+\]: syslog_test\.go:\d+: (\w+)$`)
+	var got []string
+	for msg := range msgs {
+		m := re.FindStringSubmatch(msg)
+		if m == nil {
+			t.Fatalf("unexpected message %q", msg)
+		}
+		got = append(got, m[1]+" "+m[2])
+	}
+	// user(1)*8 + error(3) = 11, user*8 + debug(7) = 15
+	if strings.Join(got, ",") != "11 first,15 second" {
+		t.Errorf("got %v", got)
+	}
+}